@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/netsoc/cli/pkg/cmd"
@@ -9,6 +10,11 @@ import (
 
 func main() {
 	if err := cmd.NewCmdRoot().Execute(); err != nil {
+		var exitErr *util.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 
@@ -5,14 +5,16 @@ go 1.16
 require (
 	github.com/MakeNowJust/heredoc v1.0.0
 	github.com/Masterminds/semver/v3 v3.1.1
+	github.com/PaesslerAG/jsonpath v0.1.1
 	github.com/containerd/console v1.0.2
 	github.com/dgrijalva/jwt-go/v4 v4.0.0-preview1
 	github.com/dustin/go-humanize v1.0.0
 	github.com/githubnemo/CompileDaemon v1.3.0
 	github.com/google/go-github/v32 v32.1.0
 	github.com/gorilla/websocket v1.4.2
+	github.com/itchyny/gojq v0.12.8
 	github.com/jedib0t/go-pretty/v6 v6.2.4
-	github.com/mattn/go-isatty v0.0.13
+	github.com/mattn/go-isatty v0.0.14
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/netsoc/iam/client v1.0.11
@@ -14,7 +14,7 @@ func NewCmdAccount(f *util.CmdFactory) *cobra.Command {
 		Short:   "Manage Netsoc account",
 	}
 
-	cmd.AddCommand(NewCmdLogin(f), NewCmdLogout(f), NewCmdInfo(f), NewCmdSet(f), NewCmdDelete(f))
+	cmd.AddCommand(NewCmdLogin(f), NewCmdLogout(f), NewCmdInfo(f), NewCmdSet(f), NewCmdDelete(f), NewCmdToken(f))
 	// Admin-only commands
 	cmd.AddCommand(NewCmdList(f), NewCmdIssue(f))
 
@@ -2,18 +2,16 @@ package account
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 	"github.com/spf13/cobra"
 )
 
 type deleteOptions struct {
-	Config    func() (*config.Config, error)
+	Token     func() (string, error)
 	IAMClient func() (*iam.APIClient, error)
 
 	NoConfirm bool
@@ -23,7 +21,7 @@ type deleteOptions struct {
 // NewCmdDelete creates a new account delete command
 func NewCmdDelete(f *util.CmdFactory) *cobra.Command {
 	opts := deleteOptions{
-		Config:    f.Config,
+		Token:     f.Token,
 		IAMClient: f.IAMClient,
 	}
 	cmd := &cobra.Command{
@@ -41,15 +39,11 @@ func NewCmdDelete(f *util.CmdFactory) *cobra.Command {
 }
 
 func deleteRun(opts deleteOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	prompt := "Really delete yourself?"
 	if opts.User != "self" {
 		prompt = fmt.Sprintf("Really delete user %v?", opts.User)
@@ -70,7 +64,7 @@ func deleteRun(opts deleteOptions) error {
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 
 	if _, _, err := client.UsersApi.DeleteUser(ctx, opts.User); err != nil {
 		return util.APIError(err)
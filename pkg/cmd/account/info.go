@@ -2,28 +2,27 @@ package account
 
 import (
 	"context"
-	"errors"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 )
 
 type infoOptions struct {
-	Config    func() (*config.Config, error)
+	Token     func() (string, error)
 	IAMClient func() (*iam.APIClient, error)
 
-	OutputFormat string
-	User         string
+	Output printer.Options
+	User   string
 }
 
 // NewCmdInfo creates a new account info command
 func NewCmdInfo(f *util.CmdFactory) *cobra.Command {
 	opts := infoOptions{
-		Config:    f.Config,
+		Token:     f.Token,
 		IAMClient: f.IAMClient,
 	}
 	cmd := &cobra.Command{
@@ -41,32 +40,28 @@ func NewCmdInfo(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptFormat(cmd, &opts.OutputFormat)
+	util.AddOptFormat(cmd, &opts.Output)
 	util.AddOptUser(cmd, &opts.User)
 
 	return cmd
 }
 
 func infoRun(opts infoOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.IAMClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 
 	u, _, err := client.UsersApi.GetUser(ctx, opts.User)
 	if err != nil {
 		return util.APIError(err)
 	}
 
-	return util.PrintUsers([]iam.User{u}, opts.OutputFormat, true)
+	return printer.Print(u, opts.Output)
 }
@@ -10,23 +10,39 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 )
 
 type issueOptions struct {
-	Config    func() (*config.Config, error)
-	IAMClient func() (*iam.APIClient, error)
+	Config          func() (*config.Config, error)
+	ConfigFile      func() (*config.File, error)
+	CredentialStore func() (credentials.Store, error)
+	Token           func() (string, error)
+	IAMClient       func() (*iam.APIClient, error)
 
-	Username string
-	Duration string
+	Username      string
+	Duration      string
+	Output        printer.Options
+	SaveAsProfile string
+}
+
+// issuedToken is the output of account issue (for structured output formats)
+type issuedToken struct {
+	Token  string      `json:"token" yaml:"token"`
+	Claims tokenClaims `json:"claims" yaml:"claims"`
 }
 
 // NewCmdIssue creates a new account issue command
 func NewCmdIssue(f *util.CmdFactory) *cobra.Command {
 	opts := issueOptions{
-		Config:    f.Config,
-		IAMClient: f.IAMClient,
+		Config:          f.Config,
+		ConfigFile:      f.ConfigFile,
+		CredentialStore: f.CredentialStore,
+		Token:           f.Token,
+		IAMClient:       f.IAMClient,
 	}
 	cmd := &cobra.Command{
 		Use:   "issue <username> <duration>",
@@ -34,6 +50,12 @@ func NewCmdIssue(f *util.CmdFactory) *cobra.Command {
 		Long: heredoc.Doc(`
 			Issue a token for a user. duration is a Go duration
 			(see https://golang.org/pkg/time/#ParseDuration for details).
+
+			With --save-as-profile, the token will be stored for the given
+			profile. If the profile doesn't exist, it will be created with
+			the selected profile's URLs and the user set to username. An
+			existing profile must be for username (or have no user set, in
+			which case its user is set to username).
 		`),
 		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -44,32 +66,131 @@ func NewCmdIssue(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
+	printer.AddFlags(cmd, &opts.Output, "token", "token")
+	cmd.Flags().StringVar(&opts.SaveAsProfile, "save-as-profile", "", "store the token for the given profile (creating it if necessary)")
+
 	return cmd
 }
 
 func issueRun(opts issueOptions) error {
-	c, err := opts.Config()
-	if err != nil {
-		return err
+	if opts.SaveAsProfile != "" {
+		if !config.ValidProfileName(opts.SaveAsProfile) {
+			return fmt.Errorf("invalid profile name %q (must be lowercase alphanumeric, dashes or underscores)",
+				opts.SaveAsProfile)
+		}
+
+		c, err := opts.Config()
+		if err != nil {
+			return err
+		}
+		if opts.SaveAsProfile == c.ProfileName {
+			return errors.New("refusing to replace the selected profile's token")
+		}
+		if p, ok := c.Profiles[opts.SaveAsProfile]; ok && p.User != "" && p.User != opts.Username {
+			return fmt.Errorf("refusing to store a token for %v in profile %v (which is for %v)", opts.Username,
+				opts.SaveAsProfile, p.User)
+		}
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	client, err := opts.IAMClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 
 	r, _, err := client.UsersApi.IssueToken(ctx, opts.Username, iam.IssueTokenRequest{Duration: opts.Duration})
 	if err != nil {
 		return util.APIError(err)
 	}
 
-	log.Println("New token:")
-	fmt.Println(r.Token)
+	if opts.SaveAsProfile != "" {
+		if err := saveIssuedToken(opts, r.Token); err != nil {
+			return err
+		}
+	}
+
+	return printIssuedToken(r.Token, opts.Output)
+}
+
+// saveIssuedToken stores an issued token for a profile (creating it if necessary, or setting its user if it has none)
+func saveIssuedToken(opts issueOptions, token string) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	p, exists := c.Profiles[opts.SaveAsProfile]
+	if !exists || p.User == "" {
+		f, err := opts.ConfigFile()
+		if err != nil {
+			return err
+		}
 
+		settings := map[string]interface{}{"user": opts.Username}
+		if !exists {
+			settings["allow_insecure"] = c.AllowInsecure
+			settings["urls.iam"] = c.URLs.IAM
+			settings["urls.webspaced"] = c.URLs.Webspaced
+		}
+
+		prefix := "profiles." + opts.SaveAsProfile + "."
+		for k, v := range settings {
+			if err := f.Set(prefix+k, v); err != nil {
+				return err
+			}
+		}
+		if err := f.Write(); err != nil {
+			return err
+		}
+
+		if exists {
+			log.Printf("Set user of profile %v to %v", opts.SaveAsProfile, opts.Username)
+		} else {
+			log.Printf("Added profile %v", opts.SaveAsProfile)
+		}
+	}
+
+	store, err := opts.CredentialStore()
+	if err != nil {
+		return err
+	}
+	if err := credentials.StoreToken(store, opts.ConfigFile, opts.SaveAsProfile, token); err != nil {
+		return fmt.Errorf("failed to store token: %w", err)
+	}
+
+	log.Printf("Saved token for profile %v", opts.SaveAsProfile)
 	return nil
 }
+
+func printIssuedToken(token string, output printer.Options) error {
+	if output.Format == "token" {
+		log.Println("New token:")
+		fmt.Println(token)
+		return nil
+	}
+
+	claims, err := util.ParseClaims(token)
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return printer.Print(issuedToken{Token: token, Claims: newTokenClaims(claims)}, output)
+}
+
+func init() {
+	printer.Register(issuedToken{}, printer.TableSpec{
+		Columns: []printer.Column{
+			{Header: "Token", Value: func(i interface{}) string {
+				return i.(issuedToken).Token
+			}},
+			{Header: "Expires at", Value: func(i interface{}) string {
+				return formatClaimTime(i.(issuedToken).Claims.ExpiresAt)
+			}},
+		},
+	})
+}
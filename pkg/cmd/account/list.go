@@ -2,26 +2,27 @@ package account
 
 import (
 	"context"
-	"errors"
+	"sort"
 
 	"github.com/MakeNowJust/heredoc"
-	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 	"github.com/spf13/cobra"
 )
 
 type listOptions struct {
-	Config    func() (*config.Config, error)
+	Token     func() (string, error)
 	IAMClient func() (*iam.APIClient, error)
 
-	OutputFormat string
+	Output printer.Options
+	Watch  printer.WatchOptions
 }
 
 // NewCmdList creates a new account list command
 func NewCmdList(f *util.CmdFactory) *cobra.Command {
 	opts := listOptions{
-		Config:    f.Config,
+		Token:     f.Token,
 		IAMClient: f.IAMClient,
 	}
 	cmd := &cobra.Command{
@@ -29,40 +30,63 @@ func NewCmdList(f *util.CmdFactory) *cobra.Command {
 		Aliases: []string{"users"},
 		Short:   "(admin only) List users",
 		Long: heredoc.Doc(`
-			Prints details about users.
+			Prints details about users (sorted by ID unless --sort-by is
+			given).
 
-			A number of output format options are available.
+			A number of output format options are available. For example, to
+			list usernames and emails without decoration, most recently
+			renewed first:
+
+			  netsoc account list --sort-by .renewed \
+			    -o custom-columns=USER:.username,EMAIL:.email \
+			    --table-style plain --no-headers | tac
+
+			For spreadsheets, "-o csv" prints every field (dates in ISO 8601,
+			unset dates empty). "-o ndjson" prints one user per line.
 		`),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return listRun(opts)
 		},
 	}
 
-	util.AddOptFormat(cmd, &opts.OutputFormat)
+	util.AddOptFormat(cmd, &opts.Output)
+	util.AddOptWatch(cmd, &opts.Watch)
 
 	return cmd
 }
 
 func listRun(opts listOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.IAMClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
+
+	fetch := func() (interface{}, error) {
+		u, _, err := client.UsersApi.GetUsers(ctx)
+		if err != nil {
+			return nil, util.APIError(err)
+		}
+
+		sort.Slice(u, func(i, j int) bool {
+			return u[i].Id < u[j].Id
+		})
+
+		return u, nil
+	}
+	if opts.Watch.Enabled {
+		return printer.Watch(fetch, opts.Output, opts.Watch)
+	}
 
-	u, _, err := client.UsersApi.GetUsers(ctx)
+	u, err := fetch()
 	if err != nil {
-		return util.APIError(err)
+		return err
 	}
 
-	return util.PrintUsers(u, opts.OutputFormat, false)
+	return printer.Print(u, opts.Output)
 }
@@ -5,9 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
@@ -17,6 +18,7 @@ import (
 type loginOptions struct {
 	Config    func() (*config.Config, error)
 	IAMClient func() (*iam.APIClient, error)
+	Login     func(username string) (string, error)
 
 	Force    bool
 	Username string
@@ -27,13 +29,36 @@ func NewCmdLogin(f *util.CmdFactory) *cobra.Command {
 	opts := loginOptions{
 		Config:    f.Config,
 		IAMClient: f.IAMClient,
+		Login:     f.Login,
 	}
 	cmd := &cobra.Command{
-		Use:   "login <username>",
+		Use:   "login [username]",
 		Short: "Log in to account",
-		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Log in to account. If username is not provided, the selected
+			profile's user will be used.
+
+			By default, the token is stored in plaintext in the config file
+			(credential_store.type "config"), so anyone who can read the file
+			can use it. To store tokens somewhere else, set
+			credential_store.type to one of:
+
+			  encrypted  a file encrypted with a passphrase (or with
+			             credential_store.key_file)
+			  pass       pass (https://www.passwordstore.org)
+			  helper     an external credential helper program
+			             (credential_store.helper)
+
+			and log in again, e.g.:
+
+			  netsoc config set credential_store.type encrypted
+			  netsoc account login --force
+		`),
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts.Username = args[0]
+			if len(args) == 1 {
+				opts.Username = args[0]
+			}
 			return loginRun(opts)
 		},
 	}
@@ -49,12 +74,24 @@ func loginRun(opts loginOptions) error {
 		return err
 	}
 
+	if opts.Username == "" {
+		if c.User == "" {
+			return fmt.Errorf("no username provided and profile %v has no user set", c.ProfileName)
+		}
+
+		opts.Username = c.User
+	}
+
 	client, err := opts.IAMClient()
 	if err != nil {
 		return err
 	}
-	if c.Token != "" && !opts.Force {
-		ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	token, err := c.Token()
+	if err != nil {
+		return err
+	}
+	if token != "" && !opts.Force && !tokenExpired(token) {
+		ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 		u, _, err := client.UsersApi.GetUser(ctx, "self")
 		if err != nil {
 			log.Printf("failed to get info about user: %v", util.APIError(err))
@@ -64,22 +101,21 @@ func loginRun(opts loginOptions) error {
 		return fmt.Errorf(fmt.Sprintf("already logged in as %v", u.Username))
 	}
 
-	p, err := util.ReadPassword(false)
-	if err != nil {
-		return fmt.Errorf("failed to get password: %w", err)
-	}
-
-	t, _, err := client.UsersApi.Login(context.Background(), opts.Username, iam.LoginRequest{Password: p})
-	if err != nil {
-		return util.APIError(err)
-	}
-
-	viper.Set("token", t.Token)
-	if err := viper.WriteConfig(); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if _, err := opts.Login(opts.Username); err != nil {
+		return err
 	}
 
 	log.Println("Logged in successfully")
 
 	return nil
 }
+
+// tokenExpired checks if a token has expired (an expired token can be replaced without --force)
+func tokenExpired(token string) bool {
+	claims, err := util.ParseClaims(token)
+	if err != nil || claims.ExpiresAt == nil {
+		return false
+	}
+
+	return time.Now().After(claims.ExpiresAt.Time)
+}
@@ -7,15 +7,18 @@ import (
 	"log"
 
 	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 type logoutOptions struct {
-	Config    func() (*config.Config, error)
-	IAMClient func() (*iam.APIClient, error)
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	ConfigFile      func() (*config.File, error)
+	CredentialStore func() (credentials.Store, error)
+	IAMClient       func() (*iam.APIClient, error)
 
 	All  bool
 	User string
@@ -24,8 +27,11 @@ type logoutOptions struct {
 // NewCmdLogout creates a new account logout command
 func NewCmdLogout(f *util.CmdFactory) *cobra.Command {
 	opts := logoutOptions{
-		Config:    f.Config,
-		IAMClient: f.IAMClient,
+		Config:          f.Config,
+		Token:           f.Token,
+		ConfigFile:      f.ConfigFile,
+		CredentialStore: f.CredentialStore,
+		IAMClient:       f.IAMClient,
 	}
 	cmd := &cobra.Command{
 		Use:   "logout",
@@ -47,8 +53,9 @@ func logoutRun(opts logoutOptions) error {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	if opts.All {
@@ -56,7 +63,7 @@ func logoutRun(opts logoutOptions) error {
 		if err != nil {
 			return err
 		}
-		ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+		ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 
 		u, _, err := client.UsersApi.GetUser(ctx, "self")
 		if err != nil {
@@ -76,9 +83,12 @@ func logoutRun(opts logoutOptions) error {
 		return errors.New("user provided but `--all` not passed")
 	}
 
-	viper.Set("token", "")
-	if err := viper.WriteConfig(); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	store, err := opts.CredentialStore()
+	if err != nil {
+		return err
+	}
+	if err := credentials.EraseToken(store, opts.ConfigFile, c.ProfileName); err != nil {
+		return fmt.Errorf("failed to remove token: %w", err)
 	}
 
 	log.Println("Logged out successfully")
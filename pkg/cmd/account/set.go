@@ -2,7 +2,6 @@ package account
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 
@@ -11,13 +10,12 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	iam "github.com/netsoc/iam/client"
 )
 
 type setOptions struct {
-	Config    func() (*config.Config, error)
+	Token     func() (string, error)
 	IAMClient func() (*iam.APIClient, error)
 
 	User     string
@@ -28,7 +26,7 @@ type setOptions struct {
 // NewCmdSet creates a new account set command
 func NewCmdSet(f *util.CmdFactory) *cobra.Command {
 	opts := setOptions{
-		Config:    f.Config,
+		Token:     f.Token,
 		IAMClient: f.IAMClient,
 	}
 
@@ -80,15 +78,11 @@ func NewCmdSet(f *util.CmdFactory) *cobra.Command {
 }
 
 func setRun(opts setOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	patch := map[string]string{opts.Property: opts.Value}
 	var patchUser iam.User
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
@@ -111,7 +105,7 @@ func setRun(opts setOptions) error {
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
 
 	if _, _, err := client.UsersApi.UpdateUser(ctx, opts.User, patchUser); err != nil {
 		return util.APIError(err)
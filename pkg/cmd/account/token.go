@@ -0,0 +1,140 @@
+package account
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// NewCmdToken creates a new account token command
+func NewCmdToken(f *util.CmdFactory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Inspect tokens",
+		Long: heredoc.Doc(`
+			Inspect tokens. By default, the selected profile's token is used.
+			A token can also be passed as an argument (or "-" to read it from
+			stdin).
+		`),
+	}
+
+	cmd.AddCommand(NewCmdTokenShow(f), NewCmdTokenCheck(f))
+
+	return cmd
+}
+
+// tokenClaims is a friendlier representation of util.UserClaims
+type tokenClaims struct {
+	Subject   string     `json:"subject" yaml:"subject"`
+	Issuer    string     `json:"issuer" yaml:"issuer"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty" yaml:"issued_at,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty" yaml:"not_before,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	Expired   bool       `json:"expired" yaml:"expired"`
+	IsAdmin   bool       `json:"is_admin" yaml:"is_admin"`
+	Version   uint       `json:"version" yaml:"version"`
+}
+
+func newTokenClaims(claims *util.UserClaims) tokenClaims {
+	c := tokenClaims{
+		Subject: claims.Subject,
+		Issuer:  claims.Issuer,
+		IsAdmin: claims.IsAdmin,
+		Version: claims.Version,
+	}
+	if claims.IssuedAt != nil {
+		c.IssuedAt = &claims.IssuedAt.Time
+	}
+	if claims.NotBefore != nil {
+		c.NotBefore = &claims.NotBefore.Time
+	}
+	if claims.ExpiresAt != nil {
+		c.ExpiresAt = &claims.ExpiresAt.Time
+		c.Expired = time.Now().After(claims.ExpiresAt.Time)
+	}
+
+	return c
+}
+
+// readToken gets the token to inspect, either from an argument or the selected profile
+func readToken(c *config.Config, arg string) (string, error) {
+	switch arg {
+	case "":
+		token, err := c.Token()
+		if err != nil {
+			return "", err
+		}
+		if token == "" {
+			return "", util.ErrNotLoggedIn
+		}
+
+		return token, nil
+	case "-":
+		token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		token = strings.TrimSpace(token)
+		if token == "" {
+			if err != nil {
+				return "", fmt.Errorf("failed to read token: %w", err)
+			}
+			return "", errors.New("no token provided")
+		}
+
+		return token, nil
+	default:
+		return arg, nil
+	}
+}
+
+func formatClaimTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(util.TableDateFormat)
+}
+
+func init() {
+	var (
+		subject = printer.Column{Header: "Subject (user ID)", Value: func(i interface{}) string {
+			return i.(tokenClaims).Subject
+		}}
+		issuer = printer.Column{Header: "Issuer", Value: func(i interface{}) string {
+			return i.(tokenClaims).Issuer
+		}}
+		issuedAt = printer.Column{Header: "Issued at", Value: func(i interface{}) string {
+			return formatClaimTime(i.(tokenClaims).IssuedAt)
+		}}
+		notBefore = printer.Column{Header: "Not before", Value: func(i interface{}) string {
+			return formatClaimTime(i.(tokenClaims).NotBefore)
+		}}
+		expiresAt = printer.Column{Header: "Expires at", Value: func(i interface{}) string {
+			c := i.(tokenClaims)
+			if c.Expired {
+				return formatClaimTime(c.ExpiresAt) + " (expired)"
+			}
+
+			return formatClaimTime(c.ExpiresAt)
+		}}
+		admin = printer.Column{Header: "Admin", Value: func(i interface{}) string {
+			return printer.YesNo(i.(tokenClaims).IsAdmin)
+		}}
+		version = printer.Column{Header: "Version", Value: func(i interface{}) string {
+			return fmt.Sprint(i.(tokenClaims).Version)
+		}}
+	)
+
+	printer.Register(tokenClaims{}, printer.TableSpec{
+		Columns: []printer.Column{subject, issuedAt, expiresAt, admin, version},
+		Wide:    []printer.Column{subject, issuer, issuedAt, notBefore, expiresAt, admin, version},
+	})
+}
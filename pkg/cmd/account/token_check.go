@@ -0,0 +1,113 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	iam "github.com/netsoc/iam/client"
+)
+
+type tokenCheckOptions struct {
+	Config    func() (*config.Config, error)
+	IAMClient func() (*iam.APIClient, error)
+
+	Token string
+}
+
+// NewCmdTokenCheck creates a new account token check command
+func NewCmdTokenCheck(f *util.CmdFactory) *cobra.Command {
+	opts := tokenCheckOptions{
+		Config:    f.Config,
+		IAMClient: f.IAMClient,
+	}
+	cmd := &cobra.Command{
+		Use:     "check [token]",
+		Aliases: []string{"validate"},
+		Short:   "Check if a token is valid",
+		Long: heredoc.Doc(`
+			Check if a token is valid. The token is first checked locally
+			(e.g. for expiry) and then validated by IAM. If the token is
+			invalid, the reason will be explained and the exit code will be
+			non-zero.
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				opts.Token = args[0]
+			}
+
+			return tokenCheckRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func tokenCheckRun(opts tokenCheckOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := readToken(c, opts.Token)
+	if err != nil {
+		return err
+	}
+
+	claims, err := util.ParseClaims(token)
+	if err != nil {
+		log.Printf("Failed to parse token: %v", err)
+		return errors.New("token is invalid")
+	}
+
+	now := time.Now()
+	if claims.NotBefore != nil && now.Before(claims.NotBefore.Time) {
+		log.Printf("The token is not valid until %v", claims.NotBefore.Local().Format(util.TableDateFormat))
+		return errors.New("token is invalid")
+	}
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Time) {
+		log.Printf("The token expired at %v", claims.ExpiresAt.Local().Format(util.TableDateFormat))
+		return errors.New("token is invalid")
+	}
+
+	client, err := opts.IAMClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), iam.ContextAccessToken, token)
+
+	if _, err := client.UsersApi.ValidateToken(ctx); err != nil {
+		err = util.APIError(err)
+
+		var authErr *util.UnauthorizedError
+		if !errors.As(err, &authErr) {
+			return fmt.Errorf("failed to validate token: %w", err)
+		}
+
+		log.Printf("IAM rejected the token: %v", authErr.Message)
+		log.Print(heredoc.Docf(`
+			The token hasn't expired, so the most likely cause is that its version (%v) is
+			out of date. A user's token version changes when their password is changed or
+			they log out of all devices (with "netsoc account logout --all"). It is also
+			possible the user no longer exists or the token was signed for a different
+			IAM instance.
+		`, claims.Version))
+		return errors.New("token is invalid")
+	}
+
+	if claims.ExpiresAt != nil {
+		log.Printf("Token is valid (expires %v)", claims.ExpiresAt.Local().Format(util.TableDateFormat))
+	} else {
+		log.Print("Token is valid")
+	}
+
+	return nil
+}
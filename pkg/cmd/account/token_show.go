@@ -0,0 +1,66 @@
+package account
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type tokenShowOptions struct {
+	Config func() (*config.Config, error)
+
+	Output printer.Options
+	Token  string
+}
+
+// NewCmdTokenShow creates a new account token show command
+func NewCmdTokenShow(f *util.CmdFactory) *cobra.Command {
+	opts := tokenShowOptions{
+		Config: f.Config,
+	}
+	cmd := &cobra.Command{
+		Use:     "show [token]",
+		Aliases: []string{"decode"},
+		Short:   "Show the claims in a token",
+		Long: heredoc.Doc(`
+			Decode a token and show its claims. The token's signature is not
+			verified (use "account token check" to validate a token).
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				opts.Token = args[0]
+			}
+
+			return tokenShowRun(opts)
+		},
+	}
+
+	util.AddOptFormat(cmd, &opts.Output)
+
+	return cmd
+}
+
+func tokenShowRun(opts tokenShowOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := readToken(c, opts.Token)
+	if err != nil {
+		return err
+	}
+
+	claims, err := util.ParseClaims(token)
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	return printer.Print(newTokenClaims(claims), opts.Output)
+}
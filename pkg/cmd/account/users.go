@@ -0,0 +1,57 @@
+package account
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+	iam "github.com/netsoc/iam/client"
+)
+
+func init() {
+	var (
+		id = printer.Column{Header: "ID", Value: func(i interface{}) string {
+			return fmt.Sprint(i.(iam.User).Id)
+		}}
+		username = printer.Column{Header: "Username", Value: func(i interface{}) string {
+			return i.(iam.User).Username
+		}}
+		admin = printer.Column{Header: "Admin", Value: func(i interface{}) string {
+			u := i.(iam.User)
+			return printer.YesNo(u.IsAdmin != nil && *u.IsAdmin)
+		}}
+		email = printer.Column{Header: "Email", Value: func(i interface{}) string {
+			return i.(iam.User).Email
+		}}
+		verified = printer.Column{Header: "Verified", Value: func(i interface{}) string {
+			u := i.(iam.User)
+			return printer.YesNo(u.Verified != nil && *u.Verified)
+		}}
+		name = printer.Column{Header: "Name", Value: func(i interface{}) string {
+			u := i.(iam.User)
+			return u.FirstName + " " + u.LastName
+		}}
+		renewed = printer.Column{Header: "Renewed", Value: func(i interface{}) string {
+			u := i.(iam.User)
+			if u.Renewed.Before(time.Unix(0, 0)) {
+				return "never"
+			}
+
+			return u.Renewed.Local().Format(util.TableDateFormat)
+		}}
+		createdUpdated = printer.Column{Header: "Created / Updated", Value: func(i interface{}) string {
+			u := i.(iam.User)
+			return u.Meta.Created.Local().Format(util.TableDateFormat) + "\n" +
+				u.Meta.Updated.Local().Format(util.TableDateFormat)
+		}}
+	)
+
+	printer.Register(iam.User{}, printer.TableSpec{
+		Key: func(i interface{}) string {
+			return fmt.Sprint(i.(iam.User).Id)
+		},
+		Columns: []printer.Column{id, username, email, name, renewed},
+		Wide:    []printer.Column{id, username, admin, email, verified, name, renewed, createdUpdated},
+	})
+}
@@ -0,0 +1,71 @@
+package cliconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// NewCmdConfig creates a new CLI config management command
+func NewCmdConfig(f *util.CmdFactory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage CLI configuration",
+		Long: heredoc.Doc(`
+			View and modify the Netsoc CLI's configuration.
+
+			Keys are dot-separated paths (e.g. "debug" or "profiles.default.urls.iam").
+			Profile settings (token, allow_insecure, user and urls.*) can be given
+			without the "profiles.<name>." prefix to refer to the selected profile.
+		`),
+	}
+
+	cmd.AddCommand(NewCmdGet(f), NewCmdSet(f), NewCmdUnset(f), NewCmdView(f), NewCmdPath(f), NewCmdEdit(f))
+
+	return cmd
+}
+
+func formatValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// lookupValue returns the effective value of a key (formatted) and where it came from. Profile tokens are looked up
+// through the credential store (the same way as the token used for requests).
+func lookupValue(c *config.Config, store func() (credentials.Store, error),
+	configSource func(key string) (config.Source, error), key string) (string, string, error) {
+	v, err := config.Lookup(c, key)
+	if err != nil {
+		return "", "", err
+	}
+	source, err := configSource(key)
+	if err != nil {
+		return "", "", err
+	}
+	value := formatValue(v)
+
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] != "profiles" || parts[2] != "token" {
+		return value, source.String(), nil
+	}
+
+	token, err := credentials.Token(store, parts[1], value)
+	if err != nil {
+		return "", "", err
+	}
+	if token != value {
+		return token, fmt.Sprintf("credential store (%v)", c.CredentialStore.Type), nil
+	}
+
+	return value, source.String(), nil
+}
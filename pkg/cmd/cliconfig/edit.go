@@ -0,0 +1,121 @@
+package cliconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type editOptions struct {
+	ConfigPath func() string
+}
+
+// NewCmdEdit creates a new config edit command
+func NewCmdEdit(f *util.CmdFactory) *cobra.Command {
+	opts := editOptions{
+		ConfigPath: f.ConfigPath,
+	}
+	cmd := &cobra.Command{
+		Use:   "edit",
+		Short: "Edit config file",
+		Long: heredoc.Doc(`
+			Open the config file in an editor ($VISUAL or $EDITOR). The edited
+			file is validated before replacing the existing config.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return editRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func editor() []string {
+	for _, v := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.Fields(os.Getenv(v)); len(e) > 0 {
+			return e
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+func editRun(opts editOptions) error {
+	path := opts.ConfigPath()
+	original, err := ioutil.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile("", "netsoc-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(original)
+	tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	e := editor()
+	for {
+		cmd := exec.Command(e[0], append(e[1:], tmp.Name())...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run editor: %w", err)
+		}
+
+		edited, err := ioutil.ReadFile(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read edited file: %w", err)
+		}
+		if bytes.Equal(edited, original) {
+			log.Print("No changes made")
+			return nil
+		}
+
+		if _, err := config.Parse(edited); err != nil {
+			if !util.IsInteractive() {
+				return err
+			}
+
+			log.Print(err)
+			again, err := util.YesNo("Edit again?", true)
+			if err != nil {
+				return err
+			}
+			if again {
+				continue
+			}
+
+			return errors.New("edited config is invalid, changes discarded")
+		}
+
+		if err := config.WriteAtomic(path, edited); err != nil {
+			return fmt.Errorf("failed to write config file: %w", err)
+		}
+
+		log.Print("Config updated")
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package cliconfig
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type getOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigSource    func(key string) (config.Source, error)
+	CredentialStore func() (credentials.Store, error)
+
+	Key        string
+	ShowSource bool
+}
+
+// NewCmdGet creates a new config get command
+func NewCmdGet(f *util.CmdFactory) *cobra.Command {
+	opts := getOptions{
+		Config:          f.Config,
+		ConfigSource:    f.ConfigSource,
+		CredentialStore: f.CredentialStore,
+	}
+	cmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Get effective config value",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Key = args[0]
+			return getRun(opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.ShowSource, "show-source", "s", false, "show where the value came from")
+
+	return cmd
+}
+
+func getRun(opts getOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	key := config.ExpandKey(opts.Key, c.ProfileName)
+	value, source, err := lookupValue(c, opts.CredentialStore, opts.ConfigSource, key)
+	if err != nil {
+		return err
+	}
+
+	if !opts.ShowSource {
+		fmt.Println(value)
+		return nil
+	}
+
+	fmt.Printf("%v\t%v\n", value, source)
+	return nil
+}
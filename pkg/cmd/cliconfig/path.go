@@ -0,0 +1,31 @@
+package cliconfig
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type pathOptions struct {
+	ConfigPath func() string
+}
+
+// NewCmdPath creates a new config path command
+func NewCmdPath(f *util.CmdFactory) *cobra.Command {
+	opts := pathOptions{
+		ConfigPath: f.ConfigPath,
+	}
+	cmd := &cobra.Command{
+		Use:   "path",
+		Short: "Print config file path",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Println(opts.ConfigPath())
+			return nil
+		},
+	}
+
+	return cmd
+}
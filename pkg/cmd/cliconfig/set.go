@@ -0,0 +1,62 @@
+package cliconfig
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type setOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigFile func() (*config.File, error)
+
+	Key   string
+	Value string
+}
+
+// NewCmdSet creates a new config set command
+func NewCmdSet(f *util.CmdFactory) *cobra.Command {
+	opts := setOptions{
+		Config:     f.Config,
+		ConfigFile: f.ConfigFile,
+	}
+	cmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set config value",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Key = args[0]
+			opts.Value = args[1]
+
+			return setRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func setRun(opts setOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	key := config.ExpandKey(opts.Key, c.ProfileName)
+	if err := f.Set(key, opts.Value); err != nil {
+		return err
+	}
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Set %v", key)
+	return nil
+}
@@ -0,0 +1,65 @@
+package cliconfig
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type unsetOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigFile func() (*config.File, error)
+
+	Key string
+}
+
+// NewCmdUnset creates a new config unset command
+func NewCmdUnset(f *util.CmdFactory) *cobra.Command {
+	opts := unsetOptions{
+		Config:     f.Config,
+		ConfigFile: f.ConfigFile,
+	}
+	cmd := &cobra.Command{
+		Use:   "unset <key>",
+		Short: "Remove config value from file (reverting it to the default)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Key = args[0]
+			return unsetRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func unsetRun(opts unsetOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	key := config.ExpandKey(opts.Key, c.ProfileName)
+	removed, err := f.Unset(key)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%v is not set in config file", key)
+	}
+
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Unset %v", key)
+	return nil
+}
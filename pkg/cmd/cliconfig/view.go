@@ -0,0 +1,98 @@
+package cliconfig
+
+import (
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type viewOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigSource    func(key string) (config.Source, error)
+	CredentialStore func() (credentials.Store, error)
+
+	Output      printer.Options
+	ShowSecrets bool
+}
+
+type configEntry struct {
+	Key    string `json:"key" yaml:"key"`
+	Value  string `json:"value" yaml:"value"`
+	Source string `json:"source" yaml:"source"`
+}
+
+// NewCmdView creates a new config view command
+func NewCmdView(f *util.CmdFactory) *cobra.Command {
+	opts := viewOptions{
+		Config:          f.Config,
+		ConfigSource:    f.ConfigSource,
+		CredentialStore: f.CredentialStore,
+	}
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "View effective configuration",
+		Long: heredoc.Doc(`
+			View all effective config values and where each one came from
+			(default, file, env, flag or credential store).
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return viewRun(opts)
+		},
+	}
+
+	util.AddOptFormat(cmd, &opts.Output)
+	cmd.Flags().BoolVar(&opts.ShowSecrets, "show-secrets", false, "show tokens instead of redacting them")
+
+	return cmd
+}
+
+func init() {
+	printer.Register(configEntry{}, printer.TableSpec{
+		Columns: []printer.Column{
+			{Header: "Key", Value: func(i interface{}) string {
+				return i.(configEntry).Key
+			}},
+			{Header: "Value", Value: func(i interface{}) string {
+				return i.(configEntry).Value
+			}},
+			{Header: "Source", Value: func(i interface{}) string {
+				return i.(configEntry).Source
+			}},
+		},
+	})
+}
+
+func viewRun(opts viewOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	keys := config.Keys(c)
+	entries := make([]configEntry, 0, len(keys))
+	for _, k := range keys {
+		value, source, err := lookupValue(c, opts.CredentialStore, opts.ConfigSource, k)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(k, ".token") && value != "" && !opts.ShowSecrets {
+			value = "<redacted>"
+		}
+
+		entries = append(entries, configEntry{
+			Key:    k,
+			Value:  value,
+			Source: source,
+		})
+	}
+
+	return printer.Print(entries, opts.Output)
+}
@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type addOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigFile func() (*config.File, error)
+
+	Name    string
+	Profile config.Profile
+	Use     bool
+}
+
+// NewCmdAdd creates a new profile add command
+func NewCmdAdd(f *util.CmdFactory) *cobra.Command {
+	opts := addOptions{
+		Config:     f.Config,
+		ConfigFile: f.ConfigFile,
+	}
+	cmd := &cobra.Command{
+		Use:     "add <name>",
+		Aliases: []string{"create"},
+		Short:   "Add a profile",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			return addRun(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Profile.URLs.IAM, "iam-url", "https://iam.netsoc.ie/v1", "IAM API base URL")
+	cmd.Flags().StringVar(&opts.Profile.URLs.Webspaced, "webspaced-url", "https://webspaced.netsoc.ie/v1", "webspaced API base URL")
+	cmd.Flags().BoolVar(&opts.Profile.AllowInsecure, "allow-insecure", false, "skip TLS certificate verification")
+	cmd.Flags().StringVar(&opts.Profile.User, "default-user", "", "default user (for logging in and as the "+
+		"default for --user in read-only and session commands)")
+	cmd.Flags().BoolVar(&opts.Use, "use", false, "switch to the new profile")
+
+	return cmd
+}
+
+func addRun(opts addOptions) error {
+	if !config.ValidProfileName(opts.Name) {
+		return fmt.Errorf("invalid profile name %q (must be lowercase alphanumeric, dashes or underscores)", opts.Name)
+	}
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.Profiles[opts.Name]; ok {
+		return fmt.Errorf("profile %q already exists", opts.Name)
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+	prefix := "profiles." + opts.Name + "."
+	for k, v := range map[string]interface{}{
+		"allow_insecure": opts.Profile.AllowInsecure,
+		"user":           opts.Profile.User,
+		"urls.iam":       opts.Profile.URLs.IAM,
+		"urls.webspaced": opts.Profile.URLs.Webspaced,
+	} {
+		if err := f.Set(prefix+k, v); err != nil {
+			return err
+		}
+	}
+	if opts.Use {
+		if err := f.Set("current_profile", opts.Name); err != nil {
+			return err
+		}
+	}
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Added profile %v", opts.Name)
+	return nil
+}
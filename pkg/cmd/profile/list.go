@@ -0,0 +1,115 @@
+package profile
+
+import (
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type listOptions struct {
+	Config          func() (*config.Config, error)
+	CredentialStore func() (credentials.Store, error)
+
+	Output printer.Options
+}
+
+type profileInfo struct {
+	Name          string `json:"name" yaml:"name"`
+	Current       bool   `json:"current" yaml:"current"`
+	User          string `json:"user" yaml:"user"`
+	LoggedIn      bool   `json:"logged_in" yaml:"logged_in"`
+	AllowInsecure bool   `json:"allow_insecure" yaml:"allow_insecure"`
+	IAMURL        string `json:"iam_url" yaml:"iam_url"`
+	WebspacedURL  string `json:"webspaced_url" yaml:"webspaced_url"`
+}
+
+// NewCmdList creates a new profile list command
+func NewCmdList(f *util.CmdFactory) *cobra.Command {
+	opts := listOptions{
+		Config:          f.Config,
+		CredentialStore: f.CredentialStore,
+	}
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listRun(opts)
+		},
+	}
+
+	util.AddOptFormat(cmd, &opts.Output)
+
+	return cmd
+}
+
+func init() {
+	var (
+		current = printer.Column{Header: "Current", Value: func(i interface{}) string {
+			if i.(profileInfo).Current {
+				return "*"
+			}
+
+			return ""
+		}}
+		name = printer.Column{Header: "Name", Value: func(i interface{}) string {
+			return i.(profileInfo).Name
+		}}
+		user = printer.Column{Header: "User", Value: func(i interface{}) string {
+			return i.(profileInfo).User
+		}}
+		iamURL = printer.Column{Header: "IAM URL", Value: func(i interface{}) string {
+			return i.(profileInfo).IAMURL
+		}}
+		webspacedURL = printer.Column{Header: "Webspaced URL", Value: func(i interface{}) string {
+			return i.(profileInfo).WebspacedURL
+		}}
+		loggedIn = printer.Column{Header: "Logged in", Value: func(i interface{}) string {
+			return printer.YesNo(i.(profileInfo).LoggedIn)
+		}}
+		insecure = printer.Column{Header: "Allow insecure", Value: func(i interface{}) string {
+			return printer.YesNo(i.(profileInfo).AllowInsecure)
+		}}
+	)
+
+	printer.Register(profileInfo{}, printer.TableSpec{
+		Columns: []printer.Column{current, name, user, iamURL, webspacedURL},
+		Wide:    []printer.Column{current, name, user, iamURL, webspacedURL, loggedIn, insecure},
+	})
+}
+
+func listRun(opts listOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	profiles := make([]profileInfo, 0, len(c.Profiles))
+	for name, p := range c.Profiles {
+		token, err := credentials.Token(opts.CredentialStore, name, p.Token)
+		if err != nil {
+			return err
+		}
+		loggedIn := token != ""
+
+		profiles = append(profiles, profileInfo{
+			Name:          name,
+			Current:       name == c.ProfileName,
+			User:          p.User,
+			LoggedIn:      loggedIn,
+			AllowInsecure: p.AllowInsecure,
+			IAMURL:        p.URLs.IAM,
+			WebspacedURL:  p.URLs.Webspaced,
+		})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	return printer.Print(profiles, opts.Output)
+}
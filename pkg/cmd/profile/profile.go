@@ -0,0 +1,35 @@
+package profile
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// NewCmdProfile creates a new profile management command
+func NewCmdProfile(f *util.CmdFactory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "profile",
+		Aliases: []string{"context"},
+		Short:   "Manage config profiles",
+		Long: heredoc.Doc(`
+			Manage config profiles. Each profile has its own service URLs,
+			token and default user, making it easy to switch between Netsoc
+			deployments (e.g. production and a local development instance).
+
+			A profile's default user is used to log in and as the default for
+			--user in commands which only read from or open a session with a
+			webspace (e.g. "netsoc webspace status" or "netsoc webspace exec").
+			Commands which make changes always act as yourself unless --user
+			is given.
+
+			The profile to use for a single command can be selected with the
+			--profile flag (or NETSOC_PROFILE environment variable).
+		`),
+	}
+
+	cmd.AddCommand(NewCmdList(f), NewCmdUse(f), NewCmdAdd(f), NewCmdRemove(f), NewCmdRename(f))
+
+	return cmd
+}
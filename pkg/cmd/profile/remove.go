@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type removeOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigFile      func() (*config.File, error)
+	CredentialStore func() (credentials.Store, error)
+
+	Name string
+}
+
+// NewCmdRemove creates a new profile remove command
+func NewCmdRemove(f *util.CmdFactory) *cobra.Command {
+	opts := removeOptions{
+		Config:          f.Config,
+		ConfigFile:      f.ConfigFile,
+		CredentialStore: f.CredentialStore,
+	}
+	cmd := &cobra.Command{
+		Use:     "remove <name>",
+		Aliases: []string{"delete", "rm"},
+		Short:   "Remove a profile",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			return removeRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func removeRun(opts removeOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.Profiles[opts.Name]; !ok {
+		return fmt.Errorf("profile %q does not exist", opts.Name)
+	}
+	if opts.Name == c.CurrentProfile {
+		return fmt.Errorf("profile %q is the current profile, switch to another first", opts.Name)
+	}
+
+	store, err := opts.CredentialStore()
+	if err != nil {
+		return err
+	}
+	if err := credentials.EraseToken(store, opts.ConfigFile, opts.Name); err != nil {
+		return fmt.Errorf("failed to remove token: %w", err)
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+	delete(f.Profiles(), opts.Name)
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Removed profile %v", opts.Name)
+	return nil
+}
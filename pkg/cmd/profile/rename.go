@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type renameOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigFile      func() (*config.File, error)
+	CredentialStore func() (credentials.Store, error)
+
+	Name    string
+	NewName string
+}
+
+// NewCmdRename creates a new profile rename command
+func NewCmdRename(f *util.CmdFactory) *cobra.Command {
+	opts := renameOptions{
+		Config:          f.Config,
+		ConfigFile:      f.ConfigFile,
+		CredentialStore: f.CredentialStore,
+	}
+	cmd := &cobra.Command{
+		Use:     "rename <name> <new name>",
+		Aliases: []string{"mv"},
+		Short:   "Rename a profile",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			opts.NewName = args[1]
+
+			return renameRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func renameRun(opts renameOptions) error {
+	if !config.ValidProfileName(opts.NewName) {
+		return fmt.Errorf("invalid profile name %q (must be lowercase alphanumeric, dashes or underscores)", opts.NewName)
+	}
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.Profiles[opts.Name]; !ok {
+		return fmt.Errorf("profile %q does not exist", opts.Name)
+	}
+	if _, ok := c.Profiles[opts.NewName]; ok {
+		return fmt.Errorf("profile %q already exists", opts.NewName)
+	}
+
+	store, err := opts.CredentialStore()
+	if err != nil {
+		return err
+	}
+	if err := credentials.MoveToken(store, opts.Name, opts.NewName); err != nil {
+		return fmt.Errorf("failed to move token: %w", err)
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+	profiles := f.Profiles()
+	profiles[opts.NewName] = f.Profile(opts.Name)
+	delete(profiles, opts.Name)
+	if opts.Name == c.CurrentProfile {
+		if err := f.Set("current_profile", opts.NewName); err != nil {
+			return err
+		}
+	}
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Renamed profile %v to %v", opts.Name, opts.NewName)
+	return nil
+}
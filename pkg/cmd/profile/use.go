@@ -0,0 +1,63 @@
+package profile
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type useOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigFile func() (*config.File, error)
+
+	Name string
+}
+
+// NewCmdUse creates a new profile use command
+func NewCmdUse(f *util.CmdFactory) *cobra.Command {
+	opts := useOptions{
+		Config:     f.Config,
+		ConfigFile: f.ConfigFile,
+	}
+	cmd := &cobra.Command{
+		Use:     "use <name>",
+		Aliases: []string{"switch"},
+		Short:   "Set the current profile",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			return useRun(opts)
+		},
+	}
+
+	return cmd
+}
+
+func useRun(opts useOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := c.Profiles[opts.Name]; !ok {
+		return fmt.Errorf("profile %q does not exist", opts.Name)
+	}
+
+	f, err := opts.ConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := f.Set("current_profile", opts.Name); err != nil {
+		return err
+	}
+	if err := f.Write(); err != nil {
+		return err
+	}
+
+	log.Printf("Switched to profile %v", opts.Name)
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/containerd/console"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type replayOptions struct {
+	File      string
+	Speed     float64
+	IdleLimit time.Duration
+}
+
+// NewCmdReplay creates a new replay command
+func NewCmdReplay() *cobra.Command {
+	opts := replayOptions{}
+	cmd := &cobra.Command{
+		Use:   "replay <file.cast>",
+		Short: "Play back a recorded session",
+		Long: heredoc.Doc(`
+			Play back a terminal session recorded with --record (on "netsoc
+			webspace exec", "login" or "console") or any other asciicast v2
+			file (e.g. made with asciinema).
+
+			--speed plays the session faster (or slower, if less than 1) and
+			--idle-limit caps how long to wait between output, skipping long
+			pauses. The idle limit defaults to the one set in the recording
+			(if any).
+
+			For example, to play back a session at double speed, waiting no
+			longer than a second between output:
+
+			  netsoc replay --speed 2 --idle-limit 1s session.cast
+		`),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.File = args[0]
+			if opts.Speed <= 0 {
+				return errors.New("speed must be greater than 0")
+			}
+			if opts.IdleLimit < 0 {
+				return errors.New("idle limit can't be negative")
+			}
+
+			return replayRun(opts)
+		},
+	}
+
+	cmd.Flags().Float64VarP(&opts.Speed, "speed", "s", 1, "playback speed `multiplier`")
+	cmd.Flags().DurationVarP(&opts.IdleLimit, "idle-limit", "i", 0, "maximum time to wait between output")
+
+	return cmd
+}
+
+func replayRun(opts replayOptions) error {
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := util.NewRecordingReader(f)
+	if err != nil {
+		return err
+	}
+
+	idleLimit := opts.IdleLimit
+	if idleLimit == 0 && r.Header.IdleTimeLimit > 0 {
+		idleLimit = time.Duration(r.Header.IdleTimeLimit * float64(time.Second))
+	}
+
+	if util.IsInteractive() {
+		if s, err := console.Current().Size(); err == nil && (int(s.Width) < r.Header.Width || int(s.Height) < r.Header.Height) {
+			log.Printf("Warning: recording is %vx%v but the terminal is only %vx%v", r.Header.Width, r.Header.Height,
+				s.Width, s.Height)
+		}
+	}
+
+	var last float64
+	var pos time.Duration
+	start := time.Now()
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delay := time.Duration((e.Time - last) * float64(time.Second))
+		last = e.Time
+		if idleLimit != 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		// Sleep until an absolute position in the playback so output isn't delayed further by time spent writing it
+		pos += time.Duration(float64(delay) / opts.Speed)
+		time.Sleep(time.Until(start.Add(pos)))
+
+		switch e.Type {
+		case util.RecordingEventOutput:
+			if _, err := fmt.Print(e.Data); err != nil {
+				return err
+			}
+		case util.RecordingEventResize:
+			util.Debugf("Recorded terminal resized to %v", e.Data)
+		default:
+			util.Debugf("Skipping recording event of type %v", e.Type)
+		}
+	}
+}
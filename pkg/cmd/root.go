@@ -8,9 +8,10 @@ import (
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
 	"github.com/netsoc/cli/pkg/cmd/account"
+	"github.com/netsoc/cli/pkg/cmd/cliconfig"
+	"github.com/netsoc/cli/pkg/cmd/profile"
 	"github.com/netsoc/cli/pkg/cmd/webspace"
 	"github.com/netsoc/cli/pkg/util"
 )
@@ -33,12 +34,40 @@ func NewCmdRoot() *cobra.Command {
 
 	cmd.PersistentFlags().String("config", defaultConfig, "config file")
 	cmd.PersistentFlags().Bool("debug", false, "print debug messages")
-	f := util.NewDefaultCmdFactory(cmd.PersistentFlags().Lookup("config"), cmd.PersistentFlags().Lookup("debug"))
+	cmd.PersistentFlags().StringP("profile", "P", "", "config profile to use (overrides the current profile)")
+	f := util.NewDefaultCmdFactory(
+		cmd.PersistentFlags().Lookup("config"),
+		cmd.PersistentFlags().Lookup("debug"),
+		cmd.PersistentFlags().Lookup("profile"),
+	)
 
 	cmd.AddCommand(account.NewCmdAccount(f))
+	cmd.AddCommand(profile.NewCmdProfile(f), cliconfig.NewCmdConfig(f))
 	cmd.AddCommand(webspace.NewCmdWebspace(f))
+	cmd.AddCommand(NewCmdReplay())
 	cmd.AddCommand(NewCmdCompletion(), NewCmdDocs())
 	cmd.AddCommand(NewCmdVersion(f))
+	retryUnauthorized(cmd, f)
+
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// Default the user to act as to the one set in the profile, for commands which opted in with
+		// util.AddOptProfileUser
+		userFlag := cmd.Flags().Lookup("user")
+		if userFlag == nil || userFlag.Changed || userFlag.Annotations[util.AnnotationProfileUser] == nil {
+			return nil
+		}
+
+		c, err := f.Config()
+		if err != nil {
+			return err
+		}
+
+		if c.User != "" {
+			return userFlag.Value.Set(c.User)
+		}
+
+		return nil
+	}
 
 	cmd.PersistentPostRunE = func(cmd *cobra.Command, args []string) error {
 		c, err := f.Config()
@@ -60,9 +89,15 @@ func NewCmdRoot() *cobra.Command {
 			log.Printf("A new version of the Netsoc CLI is available at %v", newURL)
 		}
 
-		viper.Set("last_update_check", now)
-		if err := viper.WriteConfig(); err != nil {
-			return fmt.Errorf("failed to write config: %w", err)
+		file, err := f.ConfigFile()
+		if err != nil {
+			return err
+		}
+		if err := file.Set("last_update_check", now); err != nil {
+			return err
+		}
+		if err := file.Write(); err != nil {
+			return err
 		}
 
 		return nil
@@ -70,3 +105,29 @@ func NewCmdRoot() *cobra.Command {
 
 	return cmd
 }
+
+// retryUnauthorized wraps commands to offer logging in again (and re-run them) if their token was rejected before
+// anything was done (API requests rejected later on are retried individually by the API clients)
+func retryUnauthorized(cmd *cobra.Command, f *util.CmdFactory) {
+	for _, c := range cmd.Commands() {
+		retryUnauthorized(c, f)
+	}
+
+	run := cmd.RunE
+	if run == nil {
+		return
+	}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+
+		retry, rErr := f.Reauthenticate(err)
+		if rErr != nil {
+			return fmt.Errorf("failed to log in again: %w", rErr)
+		}
+		if !retry {
+			return err
+		}
+
+		return run(cmd, args)
+	}
+}
@@ -0,0 +1,179 @@
+package webspace
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+type applyOptions struct {
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User      string
+	File      string
+	Prune     bool
+	NoConfirm bool
+}
+
+// manifestHelp describes the manifest format
+var manifestHelp = heredoc.Doc(`
+	A manifest is a YAML file describing one or more webspaces (as separate
+	documents). For example:
+
+	  user: alice
+	  image: debian/11
+	  config:
+	    startupDelay: 3
+	    httpPort: 8080
+	    sniPassthrough: false
+	  domains:
+	    - alice.example.com
+	  ports:
+	    2222: 22
+	  running: true
+
+	user defaults to --user. The image is only used if the webspace needs to
+	be created. Fields which are omitted aren't managed. Domains and port
+	forwards which aren't in the manifest are only removed with --prune.
+	Use "netsoc webspace export" to create a manifest from existing
+	webspaces.
+`)
+
+func addManifestFlags(cmd *cobra.Command, opts *applyOptions) {
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().StringVarP(&opts.File, "filename", "f", "", "manifest `file` (- for stdin)")
+	cmd.Flags().BoolVar(&opts.Prune, "prune", false, "remove domains and ports which aren't in the manifest")
+	cmd.MarkFlagRequired("filename")
+}
+
+// NewCmdApply creates a new webspace apply command
+func NewCmdApply(f *util.CmdFactory) *cobra.Command {
+	opts := applyOptions{
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "apply -f <manifest>",
+		Short: "Apply webspace manifest",
+		Long: heredoc.Doc(`
+			Make webspaces match a manifest. The changes are printed and (when
+			running interactively) confirmed before they're made. Changes are
+			made in order: creating the webspace, setting config, removing
+			and then adding domains and port forwards and finally starting or
+			stopping the webspace.
+
+		`) + manifestHelp,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return applyRun(opts, false)
+		},
+	}
+
+	addManifestFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.NoConfirm, "yes", false, "don't ask for confirmation")
+
+	return cmd
+}
+
+// NewCmdDiff creates a new webspace diff command
+func NewCmdDiff(f *util.CmdFactory) *cobra.Command {
+	opts := applyOptions{
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "diff -f <manifest>",
+		Short: "Show changes webspace apply would make",
+		Long: heredoc.Doc(`
+			Print the changes "netsoc webspace apply" would make to match a
+			manifest, without making them. Exits with code 1 if there are any
+			changes.
+
+		`) + manifestHelp,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return applyRun(opts, true)
+		},
+	}
+
+	addManifestFlags(cmd, &opts)
+
+	return cmd
+}
+
+func applyRun(opts applyOptions, diffOnly bool) error {
+	manifests, err := readManifests(opts.File, opts.User)
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	plans := make([]webspacePlan, len(manifests))
+	changes := 0
+	for i, m := range manifests {
+		live, err := getLiveWebspace(ctx, client, m.User)
+		if err != nil {
+			return fmt.Errorf("failed to get webspace of user %v: %w", m.User, err)
+		}
+
+		if plans[i], err = planWebspace(client, token, m, live, opts.Prune); err != nil {
+			return err
+		}
+		changes += len(plans[i].Steps)
+	}
+
+	printPlans(os.Stdout, plans)
+	if diffOnly {
+		if changes != 0 {
+			util.ExitCode = 1
+		}
+
+		return nil
+	}
+	if changes == 0 {
+		return nil
+	}
+
+	if !opts.NoConfirm && util.IsInteractive() {
+		apply, err := util.YesNo(fmt.Sprintf("Apply %v changes?", changes), false)
+		if err != nil {
+			return err
+		}
+
+		if !apply {
+			return nil
+		}
+	}
+
+	for _, p := range plans {
+		for _, s := range p.Steps {
+			if err := s.apply(ctx); err != nil {
+				return fmt.Errorf("failed to apply %v %v to webspace of user %v: %w", s.Op, s.Description, p.User,
+					util.APIError(err))
+			}
+
+			util.Debugf("Applied %v %v to webspace of user %v", s.Op, s.Description, p.User)
+		}
+	}
+
+	log.Printf("Applied %v changes", changes)
+	return nil
+}
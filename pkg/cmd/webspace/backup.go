@@ -0,0 +1,618 @@
+package webspace
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/jedib0t/go-pretty/v6/progress"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// defaultBackupPaths are backed up if no paths are given (those which don't exist are skipped)
+var defaultBackupPaths = []string{"/etc", "/home", "/root", "/srv", "/opt", "/usr/local", "/var/www"}
+
+// backupMetadata describes a backup archive, it's written alongside the archive (as <archive>.json)
+type backupMetadata struct {
+	User    string    `json:"user"`
+	Created time.Time `json:"created"`
+	Paths   []string  `json:"paths"`
+	// Image is the fingerprint (or alias, if given with --image) of the webspace's image, if known
+	Image string `json:"image,omitempty"`
+	// OS is the webspace's OS (from /etc/os-release)
+	OS string `json:"os,omitempty"`
+
+	Config  webspaced.Config `json:"config"`
+	Domains []string         `json:"domains"`
+	Ports   map[string]int32 `json:"ports"`
+
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backupMetadataPath returns the path of the metadata sidecar for an archive
+func backupMetadataPath(archive string) string {
+	return archive + ".json"
+}
+
+// readBackupMetadata reads the metadata sidecar of an archive
+func readBackupMetadata(archive string) (backupMetadata, error) {
+	var m backupMetadata
+
+	data, err := ioutil.ReadFile(backupMetadataPath(archive))
+	if err != nil {
+		return m, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse backup metadata: %w", err)
+	}
+
+	return m, nil
+}
+
+func writeJSONFile(p string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(p, append(data, '\n'), 0o600)
+}
+
+// backupState records an interrupted backup download (as <archive>.part.json) so it can be resumed
+type backupState struct {
+	Metadata backupMetadata `json:"metadata"`
+	// Remote is the path of the archive in the webspace
+	Remote string `json:"remote"`
+}
+
+// backupCreateScript creates an archive of the paths in $3... (relative to /) compressed with the command $2 in a
+// temporary file, printing its path, size and SHA-256 hash along with tar's exit code (1 means some files changed while
+// being read). Missing paths are skipped if $1 is 1.
+const backupCreateScript = `
+skip=$1; compress=$2; shift 2
+command -v "${compress%% *}" >/dev/null 2>&1 || { echo "ERR ${compress%% *} isn't installed in the webspace"; exit 1; }
+cd / || exit 1
+for p; do
+	shift
+	if [ -e "$p" ] || [ -L "$p" ]; then set -- "$@" "$p"
+	elif [ "$skip" != 1 ]; then echo "ERR /$p: no such file or directory"; exit 1
+	fi
+done
+[ $# -gt 0 ] || { echo "ERR none of the paths exist"; exit 1; }
+out=$(mktemp /tmp/netsoc-backup.XXXXXX) || { echo "ERR failed to create temporary file"; exit 1; }
+st=$(mktemp) && err=$(mktemp) || { rm -f "$out" "$st"; echo "ERR failed to create temporary file"; exit 1; }
+{ tar -cf - "$@" 2>"$err"; echo $? >"$st"; } | $compress >"$out"
+z=$?; s=$(cat "$st"); msg=$(tail -n 1 "$err"); rm -f "$st" "$err"
+if [ "$z" -ne 0 ] || [ "${s:-2}" -gt 1 ]; then
+	rm -f "$out"; echo "ERR failed to create archive: ${msg:-$compress exited with code $z}"; exit 1
+fi
+echo "OK $out $(wc -c <"$out" | tr -d ' ') $(sha256sum "$out" | cut -d' ' -f1) $s"
+`
+
+// Compression commands for backupCreateScript
+const (
+	backupCompressZstd = "zstd -q -T0 -c"
+	backupCompressGzip = "gzip -c"
+)
+
+// remoteArchive is an archive created in a webspace
+type remoteArchive struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// createRemoteArchive creates an archive of paths (relative to /) in a webspace, compressed with the compress command.
+// If skipMissing is set, paths which don't exist are left out.
+func createRemoteArchive(c *config.Config, token, user, compress string, skipMissing bool,
+	paths []string) (remoteArchive, error) {
+	var a remoteArchive
+
+	skip := "0"
+	if skipMissing {
+		skip = "1"
+	}
+
+	await, _, t := util.SimpleProgress("Creating archive", 30*time.Second)
+	s, err := util.StartExecStream(c, token, user, backupCreateScript, append([]string{skip, compress}, paths...)...)
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return a, err
+	}
+	info, err := readStatus(s)
+	s.Close()
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return a, err
+	}
+	t.MarkAsDone()
+	await()
+
+	fields := strings.Fields(info)
+	if len(fields) != 4 {
+		return a, fmt.Errorf("unexpected response from webspace: %q", info)
+	}
+	a.Path, a.SHA256 = fields[0], fields[2]
+	if a.Size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return a, fmt.Errorf("unexpected response from webspace: %q", info)
+	}
+	if fields[3] == "1" {
+		log.Print("Warning: some files changed while they were being archived")
+	}
+
+	return a, nil
+}
+
+// backupReadScript prints the file $1 from offset $2
+const backupReadScript = `
+[ -f "$1" ] || { echo "ERR $1: no such file (start the backup again without --resume)"; exit 1; }
+echo OK
+tail -c +$(($2 + 1)) "$1"
+`
+
+// backupWriteScript appends to the file $1 until it's $2 bytes long, printing its size before reading
+const backupWriteScript = `
+f=$1; size=$2
+touch "$f" || { echo "ERR failed to create $f"; exit 1; }
+cur=$(wc -c <"$f" | tr -d ' ')
+[ "$cur" -le "$size" ] || { : >"$f"; cur=0; }
+echo "OK $cur"
+[ "$cur" -lt "$size" ] || exit 0
+head -c $((size - cur)) >>"$f"
+`
+
+// transferError is an error which interrupted a transfer (which can be retried)
+type transferError struct {
+	err error
+}
+
+func (e transferError) Error() string {
+	return e.err.Error()
+}
+
+func (e transferError) Unwrap() error {
+	return e.err
+}
+
+// retryTransfer calls transfer until it succeeds, returns an error other than a transferError or has been retried
+// retries times
+func retryTransfer(retries int, transfer func() error) error {
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err := transfer()
+
+		var te transferError
+		if err == nil || !errors.As(err, &te) || attempt >= retries {
+			return err
+		}
+
+		log.Printf("Transfer interrupted (%v), retrying in %v", err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > 30*time.Second {
+			delay = 30 * time.Second
+		}
+	}
+}
+
+// cleanBackupPaths converts absolute webspace paths to paths relative to / (for tar)
+func cleanBackupPaths(paths []string) ([]string, error) {
+	rel := make([]string, len(paths))
+	for i, p := range paths {
+		if !path.IsAbs(p) {
+			return nil, fmt.Errorf("backup path %v isn't absolute", p)
+		}
+
+		rel[i] = strings.TrimPrefix(path.Clean(p), "/")
+		if rel[i] == "" {
+			return nil, errors.New("backing up / isn't supported, list directories to back up instead")
+		}
+	}
+
+	return rel, nil
+}
+
+// guessImage finds the image a webspace was (probably) created from by matching its /etc/os-release against the
+// available images, returning the image's fingerprint (if found) and the OS
+func guessImage(ctx context.Context, client *webspaced.APIClient, user string) (string, string) {
+	result, _, err := client.ConsoleApi.Exec(ctx, user, webspaced.ExecRequest{Command: "cat /etc/os-release"})
+	if err != nil || result.ExitCode != 0 {
+		util.Debugf("Failed to read /etc/os-release: %v", err)
+		return "", ""
+	}
+
+	release := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(result.Stdout))
+	for sc.Scan() {
+		kv := strings.SplitN(sc.Text(), "=", 2)
+		if len(kv) == 2 {
+			release[kv[0]] = strings.Trim(kv[1], `"'`)
+		}
+	}
+	if release["ID"] == "" {
+		return "", ""
+	}
+	osName := strings.TrimSpace(release["ID"] + " " + release["VERSION_ID"])
+
+	images, _, err := client.ImagesApi.GetImages(ctx)
+	if err != nil {
+		util.Debugf("Failed to list images: %v", err)
+		return "", osName
+	}
+	for _, i := range images {
+		if !strings.EqualFold(i.Properties["os"], release["ID"]) {
+			continue
+		}
+
+		r := i.Properties["release"]
+		if r != "" && (r == release["VERSION_ID"] || r == release["VERSION_CODENAME"]) {
+			return i.Fingerprint, osName
+		}
+	}
+
+	return "", osName
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 hash of a file
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// downloadFile downloads the file remote from a webspace, appending to the (partial) local file f until it's size
+// bytes long
+func downloadFile(c *config.Config, token, user, remote string, size int64, f *os.File, t *progress.Tracker) error {
+	off, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	t.SetValue(off)
+	if off == size {
+		return nil
+	}
+	if off > size {
+		return fmt.Errorf("partial download is larger than the archive (%v > %v bytes)", off, size)
+	}
+
+	s, err := util.StartExecStream(c, token, user, backupReadScript, remote, strconv.FormatInt(off, 10))
+	if err != nil {
+		return transferError{err}
+	}
+	defer s.Close()
+
+	if _, err := readStatus(s); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, io.TeeReader(io.LimitReader(s, size-off), util.ProgressWriter{Tracker: t})); err != nil {
+		return transferError{err}
+	}
+	code, err := s.Wait()
+	if err != nil {
+		return transferError{err}
+	}
+	if code != 0 {
+		return transferError{fmt.Errorf("connection closed (exit code %v)", code)}
+	}
+
+	if off, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	if off != size {
+		return transferError{fmt.Errorf("only received %v of %v bytes", off, size)}
+	}
+
+	return nil
+}
+
+// uploadFile uploads the local file f to remote in a webspace, continuing from wherever a previous upload stopped
+func uploadFile(c *config.Config, token, user string, f *os.File, size int64, remote string,
+	t *progress.Tracker) error {
+	s, err := util.StartExecStream(c, token, user, backupWriteScript, remote, strconv.FormatInt(size, 10))
+	if err != nil {
+		return transferError{err}
+	}
+	defer s.Close()
+
+	curStr, err := readStatus(s)
+	if err != nil {
+		return err
+	}
+	off, err := strconv.ParseInt(curStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected response from webspace: %q", curStr)
+	}
+	t.SetValue(off)
+
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(s, io.TeeReader(f, util.ProgressWriter{Tracker: t})); err != nil {
+		return transferError{err}
+	}
+
+	out, code, err := s.Output()
+	if err != nil {
+		return transferError{err}
+	}
+	if code != 0 {
+		return transferError{fmt.Errorf("upload failed (exit code %v): %v", code, strings.TrimSpace(out))}
+	}
+
+	return nil
+}
+
+// removeRemoteFile removes a (temporary) file from a webspace, logging failures
+func removeRemoteFile(ctx context.Context, client *webspaced.APIClient, user, p string) {
+	result, _, err := client.ConsoleApi.Exec(ctx, user, webspaced.ExecRequest{Command: "rm -f " + p})
+	if err != nil {
+		err = util.APIError(err)
+	} else if result.ExitCode != 0 {
+		err = fmt.Errorf("exit code %v", result.ExitCode)
+	}
+
+	if err != nil {
+		log.Printf("Failed to remove %v from webspace: %v", p, err)
+	}
+}
+
+// discardPendingBackup removes an interrupted backup download (and its archive in the webspace) which isn't going to
+// be resumed, so failed attempts don't keep filling up the webspace's disk
+func discardPendingBackup(ctx context.Context, client *webspaced.APIClient, user, statePath string) error {
+	data, err := ioutil.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state backupState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse backup state: %w", err)
+	}
+	if state.Metadata.User == user && state.Remote != "" {
+		util.Debugf("Removing archive %v left by an unfinished backup", state.Remote)
+		removeRemoteFile(ctx, client, user, state.Remote)
+	}
+
+	os.Remove(strings.TrimSuffix(statePath, ".json"))
+	return os.Remove(statePath)
+}
+
+type backupOptions struct {
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User    string
+	File    string
+	Paths   []string
+	Image   string
+	Resume  bool
+	Retries int
+	Timeout time.Duration
+}
+
+// NewCmdBackup creates a new webspace backup command
+func NewCmdBackup(f *util.CmdFactory) *cobra.Command {
+	opts := backupOptions{
+		Config:          f.Config,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "backup -f <file.tar.zst>",
+		Short: "Back up webspace",
+		Long: heredoc.Docf(`
+			Back up directories in a webspace (by default %v,
+			skipping those which don't exist) to a zstd-compressed tar
+			archive. The webspace's config, domains, port forwards and image
+			are written to a metadata file alongside the archive
+			(<file>.json). Use "netsoc webspace restore" to restore a backup.
+
+			The archive is created in the webspace's /tmp and then downloaded,
+			picking up where it left off if the connection drops. If the
+			download still fails, it can be continued with --resume (running
+			the backup again without --resume removes the unfinished archive
+			from the webspace first).
+
+			The webspace needs tar, zstd and stty. Databases should be dumped
+			to a file first (their data files may not be consistent).
+
+			To make regular backups, see "netsoc webspace backup schedule".
+		`, strings.Join(defaultBackupPaths, ", ")),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return backupRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().StringVarP(&opts.File, "filename", "f", "", "`file` to write the archive to (e.g. backup.tar.zst)")
+	cmd.Flags().StringSliceVar(&opts.Paths, "paths", nil, "webspace `paths` to back up")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "`image` to record in the metadata (by default it's guessed)")
+	cmd.Flags().BoolVar(&opts.Resume, "resume", false, "continue downloading a backup which previously failed")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 5, "`number` of times to retry a dropped transfer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait for the webspace to start")
+	cmd.MarkFlagRequired("filename")
+
+	cmd.AddCommand(NewCmdBackupSchedule(f), NewCmdBackupList(f), NewCmdBackupPrune(f))
+
+	return cmd
+}
+
+func backupRun(opts backupOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = backupWebspace(c, client, token, opts)
+	return err
+}
+
+// backupWebspace backs up a webspace to opts.File, returning the backup's metadata
+func backupWebspace(c *config.Config, client *webspaced.APIClient, token string,
+	opts backupOptions) (backupMetadata, error) {
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+	partPath := opts.File + ".part"
+	statePath := partPath + ".json"
+
+	var state backupState
+	if opts.Resume {
+		data, err := ioutil.ReadFile(statePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return state.Metadata, fmt.Errorf("no backup to resume for %v", opts.File)
+		}
+		if err != nil {
+			return state.Metadata, err
+		}
+
+		if err := json.Unmarshal(data, &state); err != nil {
+			return state.Metadata, fmt.Errorf("failed to parse backup state: %w", err)
+		}
+		if state.Metadata.User != opts.User {
+			return state.Metadata, fmt.Errorf("backup being resumed is of user %v", state.Metadata.User)
+		}
+	} else {
+		paths := opts.Paths
+		if len(paths) == 0 {
+			paths = defaultBackupPaths
+		}
+		rel, err := cleanBackupPaths(paths)
+		if err != nil {
+			return state.Metadata, err
+		}
+
+		live, err := getLiveWebspace(ctx, client, opts.User)
+		if err != nil {
+			return state.Metadata, err
+		}
+		if !live.Exists {
+			return state.Metadata, fmt.Errorf("user %v doesn't have a webspace", opts.User)
+		}
+
+		if err := ensureRunning(client, token, opts.User, opts.Timeout); err != nil {
+			return state.Metadata, err
+		}
+		if err := discardPendingBackup(ctx, client, opts.User, statePath); err != nil {
+			return state.Metadata, fmt.Errorf("failed to discard unfinished backup: %w", err)
+		}
+
+		m := &state.Metadata
+		*m = backupMetadata{
+			User:    opts.User,
+			Created: time.Now().UTC().Truncate(time.Second),
+			Paths:   paths,
+			Image:   opts.Image,
+			Config:  live.Config,
+			Domains: live.Domains,
+			Ports:   live.Ports,
+		}
+		a, err := createRemoteArchive(c, token, opts.User, backupCompressZstd, len(opts.Paths) == 0, rel)
+		if err != nil {
+			return state.Metadata, err
+		}
+		state.Remote, m.Size, m.SHA256 = a.Path, a.Size, a.SHA256
+
+		var image string
+		image, m.OS = guessImage(ctx, client, opts.User)
+		if m.Image == "" {
+			m.Image = image
+		}
+		if m.Image == "" {
+			log.Print("Warning: couldn't determine the webspace's image, use --image to record it")
+		}
+
+		if err := writeJSONFile(statePath, state); err != nil {
+			return state.Metadata, fmt.Errorf("failed to save backup state: %w", err)
+		}
+		os.Remove(partPath)
+	}
+
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return state.Metadata, err
+	}
+	defer f.Close()
+
+	m := state.Metadata
+	await, t := util.TransferProgress(fmt.Sprintf("Downloading backup (%v)", humanize.IBytes(uint64(m.Size))),
+		m.Size)
+	if err := retryTransfer(opts.Retries, func() error {
+		return downloadFile(c, token, opts.User, state.Remote, m.Size, f, t)
+	}); err != nil {
+		t.MarkAsErrored()
+		await()
+		return m, fmt.Errorf("failed to download backup: %w (run the same command with --resume to continue)", err)
+	}
+	t.MarkAsDone()
+	await()
+
+	if err := f.Close(); err != nil {
+		return m, err
+	}
+	sum, err := fileSHA256(partPath)
+	if err != nil {
+		return m, err
+	}
+	if sum != m.SHA256 {
+		os.Remove(partPath)
+		os.Remove(statePath)
+		removeRemoteFile(ctx, client, opts.User, state.Remote)
+		return m, errors.New("downloaded archive is corrupt (checksum mismatch), run the backup again")
+	}
+
+	if err := writeJSONFile(backupMetadataPath(opts.File), m); err != nil {
+		return m, fmt.Errorf("failed to write backup metadata: %w", err)
+	}
+	if err := os.Rename(partPath, opts.File); err != nil {
+		return m, err
+	}
+	os.Remove(statePath)
+	removeRemoteFile(ctx, client, opts.User, state.Remote)
+
+	log.Printf("Backed up %v to %v (%v)", strings.Join(m.Paths, ", "), opts.File, humanize.IBytes(uint64(m.Size)))
+	return m, nil
+}
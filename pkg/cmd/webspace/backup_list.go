@@ -0,0 +1,99 @@
+package webspace
+
+import (
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type backupListOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigPath func() string
+
+	Output printer.Options
+	User   string
+	All    bool
+}
+
+// NewCmdBackupList creates a new webspace backup list command
+func NewCmdBackupList(f *util.CmdFactory) *cobra.Command {
+	opts := backupListOptions{
+		Config:     f.Config,
+		ConfigPath: f.ConfigPath,
+	}
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List scheduled webspace backups",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return backupListRun(opts)
+		},
+	}
+
+	util.AddOptFormat(cmd, &opts.Output)
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().BoolVarP(&opts.All, "all", "a", false, "list backups of all users")
+
+	return cmd
+}
+
+func init() {
+	var (
+		user = printer.Column{Header: "User", Value: func(i interface{}) string {
+			return i.(backupRecord).User
+		}}
+		created = printer.Column{Header: "Created", Value: func(i interface{}) string {
+			return i.(backupRecord).Created.Local().Format(util.TableDateFormat)
+		}}
+		size = printer.Column{Header: "Size", Value: func(i interface{}) string {
+			return humanize.IBytes(uint64(i.(backupRecord).Size))
+		}}
+		verified = printer.Column{Header: "Verified", Value: func(i interface{}) string {
+			return printer.YesNo(i.(backupRecord).Verified)
+		}}
+		exists = printer.Column{Header: "Exists", Value: func(i interface{}) string {
+			_, err := os.Stat(i.(backupRecord).File)
+			return printer.YesNo(err == nil)
+		}}
+		file = printer.Column{Header: "File", Value: func(i interface{}) string {
+			return i.(backupRecord).File
+		}}
+		sha256 = printer.Column{Header: "SHA-256", Value: func(i interface{}) string {
+			return i.(backupRecord).SHA256
+		}}
+	)
+
+	printer.Register(backupRecord{}, printer.TableSpec{
+		Columns: []printer.Column{user, created, size, verified, file},
+		Wide:    []printer.Column{user, created, size, verified, exists, file, sha256},
+	})
+}
+
+func backupListRun(opts backupListOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	index, err := readBackupIndex(backupIndexPath(opts.ConfigPath()))
+	if err != nil {
+		return err
+	}
+
+	user := opts.User
+	if opts.All {
+		user = ""
+	}
+	backups := index.backups(c.ProfileName, user)
+	if backups == nil {
+		backups = []backupRecord{}
+	}
+
+	return printer.Print(backups, opts.Output)
+}
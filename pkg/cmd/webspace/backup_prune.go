@@ -0,0 +1,151 @@
+package webspace
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// pruneBackups deletes backups and removes them from the index, returning those which were deleted (failures are
+// logged)
+func pruneBackups(index *backupIndex, remove []backupRecord) []backupRecord {
+	var removed []backupRecord
+	for _, b := range remove {
+		if err := deleteBackup(b); err != nil {
+			log.Printf("Failed to delete %v: %v", b.File, err)
+			continue
+		}
+
+		removed = append(removed, b)
+	}
+	index.removeBackups(removed)
+
+	return removed
+}
+
+type backupPruneOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigPath func() string
+
+	User       string
+	KeepDaily  int
+	KeepWeekly int
+	DryRun     bool
+
+	changed func(flag string) bool
+}
+
+// NewCmdBackupPrune creates a new webspace backup prune command
+func NewCmdBackupPrune(f *util.CmdFactory) *cobra.Command {
+	opts := backupPruneOptions{
+		Config:     f.Config,
+		ConfigPath: f.ConfigPath,
+	}
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Prune scheduled webspace backups",
+		Long: heredoc.Doc(`
+			Delete old scheduled backups according to the retention policy of
+			the webspace's backup schedule (or --keep-daily and --keep-weekly).
+			Backups whose files have been deleted are removed from the index.
+			This is done automatically after each scheduled backup.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.changed = cmd.Flags().Changed
+			return backupPruneRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().IntVar(&opts.KeepDaily, "keep-daily", 7, "`number` of daily backups to keep")
+	cmd.Flags().IntVar(&opts.KeepWeekly, "keep-weekly", 4, "`number` of weekly backups to keep")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only print the backups which would be deleted")
+
+	return cmd
+}
+
+// planPrune finds the backups of a webspace which should be pruned and those whose files no longer exist
+func planPrune(index *backupIndex, profile string, opts backupPruneOptions) ([]backupRecord, []backupRecord, error) {
+	daily, weekly := opts.KeepDaily, opts.KeepWeekly
+	if s, ok := index.schedule(profile, opts.User); ok {
+		if !opts.changed("keep-daily") {
+			daily = s.KeepDaily
+		}
+		if !opts.changed("keep-weekly") {
+			weekly = s.KeepWeekly
+		}
+	} else if !opts.changed("keep-daily") && !opts.changed("keep-weekly") {
+		return nil, nil, fmt.Errorf("there's no backup schedule for user %v (use --keep-daily and --keep-weekly)",
+			opts.User)
+	}
+
+	var backups, missing []backupRecord
+	for _, b := range index.backups(profile, opts.User) {
+		if _, err := os.Stat(b.File); errors.Is(err, os.ErrNotExist) {
+			missing = append(missing, b)
+		} else {
+			backups = append(backups, b)
+		}
+	}
+	_, remove := retainBackups(backups, daily, weekly)
+
+	return remove, missing, nil
+}
+
+func backupPruneRun(opts backupPruneOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+	indexPath := backupIndexPath(opts.ConfigPath())
+
+	if opts.DryRun {
+		index, err := readBackupIndex(indexPath)
+		if err != nil {
+			return err
+		}
+
+		remove, missing, err := planPrune(index, c.ProfileName, opts)
+		if err != nil {
+			return err
+		}
+		for _, b := range missing {
+			log.Printf("Would forget %v (no longer exists)", b.File)
+		}
+		for _, b := range remove {
+			log.Printf("Would delete %v", b.File)
+		}
+		if len(missing) == 0 && len(remove) == 0 {
+			log.Print("No backups to prune")
+		}
+
+		return nil
+	}
+
+	return updateBackupIndex(indexPath, func(index *backupIndex) error {
+		remove, missing, err := planPrune(index, c.ProfileName, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range pruneBackups(index, missing) {
+			log.Printf("Forgot %v (no longer exists)", b.File)
+		}
+		for _, b := range pruneBackups(index, remove) {
+			log.Printf("Deleted %v", b.File)
+		}
+		if len(missing) == 0 && len(remove) == 0 {
+			log.Print("No backups to prune")
+		}
+
+		return nil
+	})
+}
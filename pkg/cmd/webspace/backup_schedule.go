@@ -0,0 +1,573 @@
+package webspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// backupIndexFile is the name of the file (next to the config file) where scheduled backups are tracked
+const backupIndexFile = "backups.json"
+
+// backupSchedule describes regular backups of a webspace to a local directory
+type backupSchedule struct {
+	Profile string   `json:"profile"`
+	User    string   `json:"user"`
+	Dir     string   `json:"dir"`
+	Paths   []string `json:"paths,omitempty"`
+	// At is the time of day (HH:MM) to run backups
+	At         string `json:"at"`
+	KeepDaily  int    `json:"keepDaily"`
+	KeepWeekly int    `json:"keepWeekly"`
+}
+
+// backupRecord is a scheduled backup
+type backupRecord struct {
+	Profile  string    `json:"profile"`
+	User     string    `json:"user"`
+	File     string    `json:"file"`
+	Created  time.Time `json:"created"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Verified bool      `json:"verified"`
+}
+
+// backupIndex tracks backup schedules and the backups they've made
+type backupIndex struct {
+	Schedules []backupSchedule `json:"schedules"`
+	Backups   []backupRecord   `json:"backups"`
+}
+
+// backupIndexPath returns the path of the backup index (next to the config file)
+func backupIndexPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), backupIndexFile)
+}
+
+// readBackupIndex reads the backup index (which is empty if it doesn't exist yet)
+func readBackupIndex(p string) (*backupIndex, error) {
+	index := &backupIndex{}
+
+	data, err := ioutil.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse backup index: %w", err)
+	}
+
+	return index, nil
+}
+
+// updateBackupIndex reads the backup index, modifies it with update and writes it back
+func updateBackupIndex(p string, update func(index *backupIndex) error) error {
+	index, err := readBackupIndex(p)
+	if err != nil {
+		return err
+	}
+
+	if err := update(index); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+
+	return config.WriteAtomic(p, append(data, '\n'))
+}
+
+// schedule finds the backup schedule for a webspace
+func (i *backupIndex) schedule(profile, user string) (*backupSchedule, bool) {
+	for n := range i.Schedules {
+		if s := &i.Schedules[n]; s.Profile == profile && s.User == user {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// backups returns the backups of a webspace (or all webspaces if user is empty), newest first
+func (i *backupIndex) backups(profile, user string) []backupRecord {
+	var backups []backupRecord
+	for _, b := range i.Backups {
+		if b.Profile == profile && (user == "" || b.User == user) {
+			backups = append(backups, b)
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+
+	return backups
+}
+
+// removeBackups removes records of backups from the index
+func (i *backupIndex) removeBackups(removed []backupRecord) {
+	files := map[string]bool{}
+	for _, b := range removed {
+		files[b.File] = true
+	}
+
+	backups := i.Backups[:0]
+	for _, b := range i.Backups {
+		if !files[b.File] {
+			backups = append(backups, b)
+		}
+	}
+	i.Backups = backups
+}
+
+// retainBackups splits backups (of a single webspace, newest first) into those to keep and remove. The newest backup
+// of each of the last daily days and weekly weeks which have backups is kept, along with the newest backup overall.
+func retainBackups(backups []backupRecord, daily, weekly int) ([]backupRecord, []backupRecord) {
+	var keep, remove []backupRecord
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for i, b := range backups {
+		t := b.Created.Local()
+		day := t.Format("2006-01-02")
+		y, w := t.ISOWeek()
+		week := fmt.Sprintf("%v-%v", y, w)
+
+		kept := i == 0
+		if !days[day] && len(days) < daily {
+			days[day] = true
+			kept = true
+		}
+		if !weeks[week] && len(weeks) < weekly {
+			weeks[week] = true
+			kept = true
+		}
+
+		if kept {
+			keep = append(keep, b)
+		} else {
+			remove = append(remove, b)
+		}
+	}
+
+	return keep, remove
+}
+
+// deleteBackup deletes a backup archive and its metadata
+func deleteBackup(b backupRecord) error {
+	for _, p := range []string{b.File, backupMetadataPath(b.File)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyBackup checks a backup archive matches its checksum and (if zstd is installed locally) is intact
+func verifyBackup(p string, meta backupMetadata) error {
+	sum, err := fileSHA256(p)
+	if err != nil {
+		return err
+	}
+	if sum != meta.SHA256 {
+		return errors.New("checksum mismatch")
+	}
+
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		util.Debugf("zstd isn't installed, only checked the checksum of %v", p)
+		return nil
+	}
+	if out, err := exec.Command(zstd, "-tq", p).CombinedOutput(); err != nil {
+		return fmt.Errorf("archive is corrupt: %v", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// parseTimeOfDay parses a time of day in the form HH:MM
+func parseTimeOfDay(at string) (int, int, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q (should be HH:MM)", at)
+	}
+
+	return t.Hour(), t.Minute(), nil
+}
+
+// backupUnitName returns the name of the systemd units for a backup schedule
+func backupUnitName(s backupSchedule) string {
+	return "netsoc-backup-" + s.Profile + "-" + s.User
+}
+
+type backupScheduleOptions struct {
+	Config     func() (*config.Config, error)
+	ConfigPath func() string
+
+	User       string
+	Dir        string
+	Paths      []string
+	At         string
+	KeepDaily  int
+	KeepWeekly int
+	Remove     bool
+	Systemd    bool
+	Crontab    bool
+
+	// changed reports whether a flag was set (only those options are updated in an existing schedule)
+	changed func(flag string) bool
+}
+
+// NewCmdBackupSchedule creates a new webspace backup schedule command
+func NewCmdBackupSchedule(f *util.CmdFactory) *cobra.Command {
+	opts := backupScheduleOptions{
+		Config:     f.Config,
+		ConfigPath: f.ConfigPath,
+	}
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Schedule webspace backups",
+		Long: heredoc.Docf(`
+			Set up regular backups of a webspace to a local directory. Backups
+			are made by "netsoc webspace backup schedule run" (which should be
+			run daily, use --systemd or --crontab to generate a systemd user
+			timer or crontab line to do so). Each backup is verified after
+			it's downloaded and old backups are then pruned: the newest backup
+			from each of the last --keep-daily days and --keep-weekly weeks is
+			kept.
+
+			Schedules and backups are tracked in %v next to the config file.
+			Since backups run unattended, the profile should have a
+			long-lived token (see "netsoc account issue").
+
+			For example, to back up daily at 04:30 to ~/backups/webspace:
+
+			  netsoc webspace backup schedule --dir ~/backups/webspace --at 04:30
+			  netsoc webspace backup schedule --systemd
+		`, backupIndexFile),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.changed = cmd.Flags().Changed
+			return backupScheduleRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Dir, "dir", "", "`directory` to store backups in")
+	cmd.Flags().StringSliceVar(&opts.Paths, "paths", nil, "webspace `paths` to back up")
+	cmd.Flags().StringVar(&opts.At, "at", "03:00", "time of day to back up at (`HH:MM`)")
+	cmd.Flags().IntVar(&opts.KeepDaily, "keep-daily", 7, "`number` of daily backups to keep")
+	cmd.Flags().IntVar(&opts.KeepWeekly, "keep-weekly", 4, "`number` of weekly backups to keep")
+	cmd.Flags().BoolVar(&opts.Remove, "remove", false, "remove the schedule (existing backups are kept)")
+	cmd.Flags().BoolVar(&opts.Systemd, "systemd", false, "print a systemd user service and timer to run backups")
+	cmd.Flags().BoolVar(&opts.Crontab, "crontab", false, "print a crontab line to run backups")
+
+	cmd.AddCommand(NewCmdBackupScheduleRun(f))
+
+	return cmd
+}
+
+func backupScheduleRun(opts backupScheduleOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+	indexPath := backupIndexPath(opts.ConfigPath())
+
+	if opts.Remove {
+		return updateBackupIndex(indexPath, func(index *backupIndex) error {
+			schedules := index.Schedules[:0]
+			for _, s := range index.Schedules {
+				if s.Profile != c.ProfileName || s.User != opts.User {
+					schedules = append(schedules, s)
+				}
+			}
+			if len(schedules) == len(index.Schedules) {
+				return fmt.Errorf("there's no backup schedule for user %v", opts.User)
+			}
+			index.Schedules = schedules
+
+			log.Printf("Removed backup schedule for user %v", opts.User)
+			return nil
+		})
+	}
+
+	var schedule backupSchedule
+	update := false
+	for _, f := range []string{"dir", "paths", "at", "keep-daily", "keep-weekly"} {
+		update = update || opts.changed(f)
+	}
+	if update {
+		if _, _, err := parseTimeOfDay(opts.At); err != nil {
+			return err
+		}
+		if opts.KeepDaily < 0 || opts.KeepWeekly < 0 {
+			return errors.New("the number of backups to keep can't be negative")
+		}
+		if _, err := cleanBackupPaths(opts.Paths); err != nil {
+			return err
+		}
+
+		if err := updateBackupIndex(indexPath, func(index *backupIndex) error {
+			s, ok := index.schedule(c.ProfileName, opts.User)
+			if !ok {
+				if opts.Dir == "" {
+					return errors.New("--dir is required to create a backup schedule")
+				}
+
+				index.Schedules = append(index.Schedules, backupSchedule{Profile: c.ProfileName, User: opts.User})
+				s = &index.Schedules[len(index.Schedules)-1]
+			}
+
+			if opts.changed("dir") {
+				dir, err := filepath.Abs(opts.Dir)
+				if err != nil {
+					return err
+				}
+				if err := os.MkdirAll(dir, 0o700); err != nil {
+					return fmt.Errorf("failed to create backup directory: %w", err)
+				}
+
+				s.Dir = dir
+			}
+			if opts.changed("paths") || !ok {
+				s.Paths = opts.Paths
+			}
+			if opts.changed("at") || !ok {
+				s.At = opts.At
+			}
+			if opts.changed("keep-daily") || !ok {
+				s.KeepDaily = opts.KeepDaily
+			}
+			if opts.changed("keep-weekly") || !ok {
+				s.KeepWeekly = opts.KeepWeekly
+			}
+
+			schedule = *s
+			return nil
+		}); err != nil {
+			return err
+		}
+	} else {
+		index, err := readBackupIndex(indexPath)
+		if err != nil {
+			return err
+		}
+
+		s, ok := index.schedule(c.ProfileName, opts.User)
+		if !ok {
+			return fmt.Errorf("there's no backup schedule for user %v (create one with --dir)", opts.User)
+		}
+		schedule = *s
+	}
+
+	paths := schedule.Paths
+	if len(paths) == 0 {
+		paths = defaultBackupPaths
+	}
+	log.Printf("Backing up %v of user %v to %v daily at %v, keeping %v daily and %v weekly backups",
+		strings.Join(paths, ", "), schedule.User, schedule.Dir, schedule.At, schedule.KeepDaily, schedule.KeepWeekly)
+
+	if !opts.Systemd && !opts.Crontab {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find netsoc executable: %w", err)
+	}
+	args := []string{
+		exe,
+		"--config", opts.ConfigPath(),
+		"--profile", schedule.Profile,
+		"webspace", "backup", "schedule", "run",
+		"--user", schedule.User,
+	}
+	for i, a := range args {
+		args[i] = shellQuote(a)
+	}
+	command := strings.Join(args, " ")
+	hour, minute, _ := parseTimeOfDay(schedule.At)
+
+	// Both cron and systemd treat % specially (even inside quotes)
+	if opts.Crontab {
+		fmt.Printf("%v %v * * * %v\n", minute, hour, strings.ReplaceAll(command, "%", `\%`))
+	}
+	if opts.Systemd {
+		name := backupUnitName(schedule)
+		fmt.Printf(heredoc.Doc(`
+			# ~/.config/systemd/user/%[1]v.service
+			[Unit]
+			Description=Back up webspace of %[2]v (netsoc profile %[3]v)
+			Wants=network-online.target
+			After=network-online.target
+
+			[Service]
+			Type=oneshot
+			ExecStart=%[4]v
+
+			# ~/.config/systemd/user/%[1]v.timer
+			[Unit]
+			Description=Daily backup of webspace of %[2]v (netsoc profile %[3]v)
+
+			[Timer]
+			OnCalendar=*-*-* %02[5]d:%02[6]d:00
+			Persistent=true
+			RandomizedDelaySec=10m
+
+			[Install]
+			WantedBy=timers.target
+
+			# Enable with: systemctl --user daemon-reload && systemctl --user enable --now %[1]v.timer
+		`), name, schedule.User, schedule.Profile, strings.ReplaceAll(command, "%", "%%"), hour, minute)
+	}
+
+	return nil
+}
+
+type backupScheduleRunOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigPath      func() string
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User    string
+	Retries int
+	Timeout time.Duration
+}
+
+// NewCmdBackupScheduleRun creates a new webspace backup schedule run command
+func NewCmdBackupScheduleRun(f *util.CmdFactory) *cobra.Command {
+	opts := backupScheduleRunOptions{
+		Config:          f.Config,
+		ConfigPath:      f.ConfigPath,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run scheduled webspace backup",
+		Long: heredoc.Doc(`
+			Back up a webspace according to its schedule (see "netsoc webspace
+			backup schedule"), verify the backup and prune old backups.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return backupScheduleRunRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().IntVar(&opts.Retries, "retries", 5, "`number` of times to retry a dropped transfer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait for the webspace to start")
+
+	return cmd
+}
+
+func backupScheduleRunRun(opts backupScheduleRunOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+	indexPath := backupIndexPath(opts.ConfigPath())
+
+	index, err := readBackupIndex(indexPath)
+	if err != nil {
+		return err
+	}
+	schedule, ok := index.schedule(c.ProfileName, opts.User)
+	if !ok {
+		return fmt.Errorf("there's no backup schedule for user %v", opts.User)
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+
+	// Scheduled backups always have a new name, so get rid of any earlier attempt which failed part way through
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+	pending, err := filepath.Glob(filepath.Join(schedule.Dir, schedule.User+"-*.tar.zst.part.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if err := discardPendingBackup(ctx, client, schedule.User, p); err != nil {
+			log.Printf("Failed to discard unfinished backup %v: %v", strings.TrimSuffix(p, ".part.json"), err)
+		}
+	}
+
+	now := time.Now().UTC()
+	file := filepath.Join(schedule.Dir, fmt.Sprintf("%v-%v.tar.zst", schedule.User, now.Format("20060102T150405Z")))
+	meta, err := backupWebspace(c, client, token, backupOptions{
+		User:    schedule.User,
+		File:    file,
+		Paths:   schedule.Paths,
+		Retries: opts.Retries,
+		Timeout: opts.Timeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := verifyBackup(file, meta); err != nil {
+		if err := deleteBackup(backupRecord{File: file}); err != nil {
+			log.Printf("Failed to remove backup which failed verification: %v", err)
+		}
+
+		return fmt.Errorf("failed to verify backup: %w", err)
+	}
+	log.Printf("Verified %v", file)
+
+	var removed []backupRecord
+	if err := updateBackupIndex(indexPath, func(index *backupIndex) error {
+		index.Backups = append(index.Backups, backupRecord{
+			Profile:  c.ProfileName,
+			User:     schedule.User,
+			File:     file,
+			Created:  meta.Created,
+			Size:     meta.Size,
+			SHA256:   meta.SHA256,
+			Verified: true,
+		})
+
+		_, remove := retainBackups(index.backups(c.ProfileName, schedule.User), schedule.KeepDaily,
+			schedule.KeepWeekly)
+		removed = pruneBackups(index, remove)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, b := range removed {
+		log.Printf("Pruned %v", b.File)
+	}
+	return nil
+}
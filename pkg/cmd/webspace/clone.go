@@ -0,0 +1,372 @@
+package webspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/jedib0t/go-pretty/v6/progress"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// cloneExtractScript checks the archive $1 has the SHA-256 hash $2 and extracts it to /, then checks every file listed
+// in the archive exists, printing how many there are (files the new webspace already had aren't counted)
+const cloneExtractScript = `
+f=$1; sum=$2
+[ "$(sha256sum "$f" | cut -d' ' -f1)" = "$sum" ] || { rm -f "$f"; echo "ERR transferred archive is corrupt"; exit 1; }
+out=$(gzip -dc "$f" | tar -xpf - -C / 2>&1) || { rm -f "$f"; echo "ERR failed to extract archive: $out"; exit 1; }
+list=$(mktemp) || { rm -f "$f"; echo "ERR failed to create temporary file"; exit 1; }
+# GNU tar escapes unusual characters in names unless told not to
+q=; tar --version 2>/dev/null | grep -q GNU && q=--quoting-style=literal
+gzip -dc "$f" | tar -tf - $q | grep -v '/$' >"$list"
+rm -f "$f"
+cd / || exit 1
+n=0; m=0; missing=
+while IFS= read -r p; do
+	n=$((n+1))
+	if [ ! -e "$p" ] && [ ! -L "$p" ]; then m=$((m+1)); missing=${missing:-/$p}; fi
+done <"$list"
+rm -f "$list"
+[ "$m" -eq 0 ] || { echo "ERR failed to verify copy: $m of $n files are missing from the new webspace (e.g. $missing)"; exit 1; }
+echo "OK $n"
+`
+
+// copyRemoteFile copies the file from in one webspace to the file to in another (through the CLI), continuing from
+// wherever a previous copy stopped
+func copyRemoteFile(c *config.Config, token, fromUser, from, toUser, to string, size int64,
+	t *progress.Tracker) error {
+	dst, err := util.StartExecStream(c, token, toUser, backupWriteScript, to, strconv.FormatInt(size, 10))
+	if err != nil {
+		return transferError{err}
+	}
+	defer dst.Close()
+
+	curStr, err := readStatus(dst)
+	if err != nil {
+		return err
+	}
+	off, err := strconv.ParseInt(curStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected response from webspace: %q", curStr)
+	}
+	t.SetValue(off)
+
+	if off < size {
+		src, err := util.StartExecStream(c, token, fromUser, backupReadScript, from, strconv.FormatInt(off, 10))
+		if err != nil {
+			return transferError{err}
+		}
+		defer src.Close()
+
+		if _, err := readStatus(src); err != nil {
+			return err
+		}
+
+		r := io.TeeReader(io.LimitReader(src, size-off), util.ProgressWriter{Tracker: t})
+		if n, err := io.Copy(dst, r); err != nil || n != size-off {
+			if err == nil {
+				err = fmt.Errorf("only received %v of %v bytes", off+n, size)
+			}
+
+			return transferError{err}
+		}
+	}
+
+	out, code, err := dst.Output()
+	if err != nil {
+		return transferError{err}
+	}
+	if code != 0 {
+		return transferError{fmt.Errorf("copy failed (exit code %v): %v", code, strings.TrimSpace(out))}
+	}
+
+	return nil
+}
+
+type cloneOptions struct {
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	FromUser  string
+	ToUser    string
+	Image     string
+	Paths     []string
+	Move      bool
+	DryRun    bool
+	NoConfirm bool
+	Retries   int
+	Timeout   time.Duration
+}
+
+// NewCmdClone creates a new webspace clone command
+func NewCmdClone(f *util.CmdFactory) *cobra.Command {
+	opts := cloneOptions{
+		Config:          f.Config,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "clone --from-user <user> --to-user <user>",
+		Short: "(admin only) Clone webspace to another user",
+		Long: heredoc.Docf(`
+			Clone a webspace to another user (who mustn't have a webspace yet).
+			The new webspace is created with the same image and config, then
+			directories (by default %v) are copied over
+			from the original webspace and its port forwards are re-created
+			(with new external ports). The changes are printed and (when
+			running interactively) confirmed before they're made.
+
+			A domain can only be used by one webspace, so with --move, domains
+			are removed from the original webspace and added to the new one
+			once the copy has been verified. Otherwise, domains are only added
+			if they're not in use.
+
+			The image is guessed from the original webspace's /etc/os-release
+			if it isn't given with --image. Both webspaces need tar, gzip,
+			sha256sum and stty.
+		`, strings.Join(defaultBackupPaths, ", ")),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.FromUser == opts.ToUser {
+				return errors.New("can't clone a webspace to the same user")
+			}
+
+			return cloneRun(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.FromUser, "from-user", "", "`user` whose webspace should be cloned")
+	cmd.Flags().StringVar(&opts.ToUser, "to-user", "", "`user` to create the new webspace for")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "`image` to create the new webspace with (by default it's guessed)")
+	cmd.Flags().StringSliceVar(&opts.Paths, "paths", nil, "webspace `paths` to copy")
+	cmd.Flags().BoolVar(&opts.Move, "move", false, "move domains to the new webspace")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only print the changes which would be made")
+	cmd.Flags().BoolVar(&opts.NoConfirm, "yes", false, "don't ask for confirmation")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 5, "`number` of times to retry a dropped transfer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait for the webspaces to start")
+	cmd.MarkFlagRequired("from-user")
+	cmd.MarkFlagRequired("to-user")
+
+	return cmd
+}
+
+func cloneRun(opts cloneOptions) error {
+	paths := opts.Paths
+	if len(paths) == 0 {
+		paths = defaultBackupPaths
+	}
+	rel, err := cleanBackupPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	src, err := getLiveWebspace(ctx, client, opts.FromUser)
+	if err != nil {
+		return fmt.Errorf("failed to get webspace of user %v: %w", opts.FromUser, err)
+	}
+	if !src.Exists {
+		return fmt.Errorf("user %v doesn't have a webspace", opts.FromUser)
+	}
+	dst, err := getLiveWebspace(ctx, client, opts.ToUser)
+	if err != nil {
+		return fmt.Errorf("failed to get webspace of user %v: %w", opts.ToUser, err)
+	}
+	if dst.Exists {
+		return fmt.Errorf("user %v already has a webspace", opts.ToUser)
+	}
+
+	image := opts.Image
+	if image == "" {
+		if !src.Running && !opts.DryRun {
+			if err := ensureRunning(client, token, opts.FromUser, opts.Timeout); err != nil {
+				return err
+			}
+			src.Running = true
+		}
+		if src.Running {
+			image, _ = guessImage(ctx, client, opts.FromUser)
+		}
+		if image == "" {
+			return fmt.Errorf("couldn't determine the image of the webspace of user %v, use --image", opts.FromUser)
+		}
+	}
+
+	// Port forwards in order of external port
+	var external []int
+	for e := range src.Ports {
+		p, _ := strconv.Atoi(e)
+		external = append(external, p)
+	}
+	sort.Ints(external)
+	domains := append([]string{}, src.Domains...)
+	sort.Strings(domains)
+
+	// The plans are only printed, the changes are made below
+	dstPlan := webspacePlan{User: opts.ToUser, Steps: []planStep{
+		{Op: "+", Description: fmt.Sprintf("webspace (image %v)", image)},
+		{Op: "~", Description: fmt.Sprintf("config.startupDelay: %v", src.Config.StartupDelay)},
+		{Op: "~", Description: fmt.Sprintf("config.httpPort: %v", src.Config.HttpPort)},
+		{Op: "~", Description: fmt.Sprintf("config.sniPassthrough: %v", src.Config.SniPassthrough)},
+		{Op: "+", Description: "files: " + strings.Join(paths, ", ")},
+	}}
+	for _, e := range external {
+		dstPlan.Steps = append(dstPlan.Steps, planStep{
+			Op:          "+",
+			Description: fmt.Sprintf("port <random> -> %v (was %v)", src.Ports[strconv.Itoa(e)], e),
+		})
+	}
+	srcPlan := webspacePlan{User: opts.FromUser}
+	for _, d := range domains {
+		dstPlan.Steps = append(dstPlan.Steps, planStep{Op: "+", Description: "domain " + d})
+		if opts.Move {
+			srcPlan.Steps = append(srcPlan.Steps, planStep{Op: "-", Description: "domain " + d})
+		}
+	}
+	printPlans(os.Stdout, []webspacePlan{srcPlan, dstPlan})
+	if opts.DryRun {
+		return nil
+	}
+
+	if !opts.NoConfirm && util.IsInteractive() {
+		clone, err := util.YesNo(fmt.Sprintf("Clone webspace of %v to %v?", opts.FromUser, opts.ToUser), false)
+		if err != nil {
+			return err
+		}
+
+		if !clone {
+			return nil
+		}
+	}
+
+	// Create the new webspace
+	await, _, t := util.SimpleProgress("Creating webspace", 0)
+	_, _, err = client.ConfigApi.Create(ctx, opts.ToUser, webspaced.InitRequest{Image: image})
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to create webspace: %w", util.APIError(err))
+	}
+	t.MarkAsDone()
+	await()
+
+	if err := patchConfig(ctx, client, token, opts.ToUser, map[string]interface{}{
+		"startupDelay":   src.Config.StartupDelay,
+		"httpPort":       src.Config.HttpPort,
+		"sniPassthrough": src.Config.SniPassthrough,
+	}); err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+
+	for _, u := range []string{opts.FromUser, opts.ToUser} {
+		if err := ensureRunning(client, token, u, opts.Timeout); err != nil {
+			return err
+		}
+	}
+
+	// Copy files
+	a, err := createRemoteArchive(c, token, opts.FromUser, backupCompressGzip, len(opts.Paths) == 0, rel)
+	if err != nil {
+		return err
+	}
+	defer removeRemoteFile(ctx, client, opts.FromUser, a.Path)
+
+	remote := "/tmp/netsoc-clone." + a.SHA256[:16]
+	await, pt := util.TransferProgress(fmt.Sprintf("Copying files (%v)", humanize.IBytes(uint64(a.Size))), a.Size)
+	if err := retryTransfer(opts.Retries, func() error {
+		return copyRemoteFile(c, token, opts.FromUser, a.Path, opts.ToUser, remote, a.Size, pt)
+	}); err != nil {
+		pt.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to copy files: %w", err)
+	}
+	pt.MarkAsDone()
+	await()
+
+	s, err := util.StartExecStream(c, token, opts.ToUser, cloneExtractScript, remote, a.SHA256)
+	if err != nil {
+		return err
+	}
+	count, err := readStatus(s)
+	s.Close()
+	if err != nil {
+		return err
+	}
+
+	var archived int
+	if _, err := fmt.Sscan(count, &archived); err != nil {
+		return fmt.Errorf("unexpected response from webspace: %q", count)
+	}
+	log.Printf("Copied %v files", archived)
+
+	// Port forwards
+	for _, e := range external {
+		i := src.Ports[strconv.Itoa(e)]
+		p, _, err := client.PortsApi.AddRandomPort(ctx, opts.ToUser, i)
+		if err != nil {
+			return fmt.Errorf("failed to forward port %v: %w", i, util.APIError(err))
+		}
+
+		log.Printf("Forwarded port %v to webspace port %v (was %v)", p.EPort, i, e)
+	}
+
+	// Domains
+	for _, d := range domains {
+		if opts.Move {
+			if _, err := client.DomainsApi.RemoveDomain(ctx, opts.FromUser, d); err != nil {
+				log.Printf("Failed to remove domain %v from webspace of %v: %v", d, opts.FromUser, util.APIError(err))
+				continue
+			}
+		}
+
+		if _, err := client.DomainsApi.AddDomain(ctx, opts.ToUser, d); err != nil {
+			err = util.APIError(err)
+			if !opts.Move {
+				log.Printf("Skipped domain %v (%v), use --move to move it", d, err)
+				continue
+			}
+
+			// Put it back
+			if _, err := client.DomainsApi.AddDomain(ctx, opts.FromUser, d); err != nil {
+				log.Printf("Failed to add domain %v back to webspace of %v: %v", d, opts.FromUser, util.APIError(err))
+			}
+			return fmt.Errorf("failed to move domain %v: %w", d, err)
+		}
+
+		if opts.Move {
+			log.Printf("Moved domain %v", d)
+		}
+	}
+
+	log.Printf("Cloned webspace of %v to %v", opts.FromUser, opts.ToUser)
+	return nil
+}
@@ -2,7 +2,6 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 
@@ -11,13 +10,12 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type configSetOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User   string
@@ -28,7 +26,7 @@ type configSetOptions struct {
 // NewCmdConfigSet creates a new webspace config set command
 func NewCmdConfigSet(f *util.CmdFactory) *cobra.Command {
 	opts := configSetOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -65,15 +63,11 @@ func NewCmdConfigSet(f *util.CmdFactory) *cobra.Command {
 }
 
 func configSetRun(opts configSetOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	patch := map[string]string{opts.Option: opts.Value}
 	var patchConfig webspaced.Config
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
@@ -93,7 +87,7 @@ func configSetRun(opts configSetOptions) error {
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if _, _, err := client.ConfigApi.UpdateConfig(ctx, opts.User, patchConfig); err != nil {
 		return util.APIError(err)
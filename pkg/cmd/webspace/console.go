@@ -17,14 +17,17 @@ import (
 
 type consoleOptions struct {
 	Config func() (*config.Config, error)
+	Token  func() (string, error)
 
-	User string
+	User   string
+	Record string
 }
 
 // NewCmdConsole creates a new webspace console command
 func NewCmdConsole(f *util.CmdFactory) *cobra.Command {
 	opts := consoleOptions{
 		Config: f.Config,
+		Token:  f.Token,
 	}
 	cmd := &cobra.Command{
 		Use:   "console",
@@ -34,7 +37,8 @@ func NewCmdConsole(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Record, "record", "", "record the session to an asciicast `file`")
 
 	return cmd
 }
@@ -45,13 +49,14 @@ func consoleRun(opts consoleOptions) error {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	log.Print("Attaching to console...")
 
-	conn, err := util.WebspacedWebsocket(c, opts.User, "console")
+	conn, err := util.WebspacedWebsocket(c, token, opts.User, "console")
 	if err != nil {
 		return fmt.Errorf("failed to open websocket connection: %w", err)
 	}
@@ -67,6 +72,17 @@ func consoleRun(opts consoleOptions) error {
 		return fmt.Errorf("failed to send initial terminal size: %w", err)
 	}
 
+	var rec *util.Recorder
+	if opts.Record != "" {
+		rec, err = util.NewRecorder(opts.Record, util.ConsoleSize{Width: int(s.Width), Height: int(s.Height)},
+			"console", map[string]string{"TERM": util.GetTERM()})
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		defer closeRecorder(rec, opts.Record)
+	}
+
 	if err := tty.SetRaw(); err != nil {
 		conn.Close()
 		return fmt.Errorf("failed to put terminal in raw mode: %w", err)
@@ -87,6 +103,10 @@ func consoleRun(opts consoleOptions) error {
 		for {
 			select {
 			case s := <-resizeChan:
+				if rec != nil {
+					rec.Resize(s)
+				}
+
 				rw.Mutex.Lock()
 				util.Debugf("Sending console resize: %v", s)
 				if err := conn.WriteJSON(s); err != nil {
@@ -107,7 +127,11 @@ func consoleRun(opts consoleOptions) error {
 		errChan <- err
 	}
 	go pipe(rw, er)
-	go pipe(os.Stdout, rw)
+	if rec != nil {
+		go pipe(os.Stdout, io.TeeReader(rw, rec))
+	} else {
+		go pipe(os.Stdout, rw)
+	}
 
 	log.Print("Attached, hit ^] (Ctrl+]) and then q to disconnect\r")
 
@@ -0,0 +1,506 @@
+package webspace
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type cpOptions struct {
+	Config func() (*config.Config, error)
+	Token  func() (string, error)
+
+	User          string
+	Source        string
+	Destination   string
+	AllowSymlinks bool
+}
+
+// NewCmdCp creates a new webspace cp command
+func NewCmdCp(f *util.CmdFactory) *cobra.Command {
+	opts := cpOptions{
+		Config: f.Config,
+		Token:  f.Token,
+	}
+	cmd := &cobra.Command{
+		Use:   "cp <source> <destination>",
+		Short: "Copy files to and from webspace",
+		Long: heredoc.Doc(`
+			Copy files or directories to or from a webspace. Paths in the
+			webspace are prefixed with ":". Permissions and modification times
+			are preserved.
+
+			If the destination is an existing directory, the source is copied
+			into it. Otherwise, the source is copied to the destination path.
+			For example, to upload a directory to /var/www/html/site:
+
+			  netsoc webspace cp ./site :/var/www/html
+
+			Or to download a file:
+
+			  netsoc webspace cp :/etc/nginx/nginx.conf nginx.conf
+
+			When downloading, symlinks which point outside of the destination
+			are refused unless --allow-symlinks is passed (files are never
+			written through symlinks either way).
+
+			The webspace needs tar, head and stty (usually available).
+		`),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Source = args[0]
+			opts.Destination = args[1]
+
+			return cpRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().BoolVar(&opts.AllowSymlinks, "allow-symlinks", false,
+		"extract downloaded symlinks which point outside of the destination")
+
+	return cmd
+}
+
+// remotePath checks if p refers to a path in the webspace (prefixed with ":")
+func remotePath(p string) (string, bool) {
+	if strings.HasPrefix(p, ":") {
+		return p[1:], true
+	}
+
+	return p, false
+}
+
+func cpRun(opts cpOptions) error {
+	src, srcRemote := remotePath(opts.Source)
+	dst, dstRemote := remotePath(opts.Destination)
+	switch {
+	case srcRemote && dstRemote:
+		return errors.New("copying between webspace paths is not supported (use exec)")
+	case !srcRemote && !dstRemote:
+		return errors.New(`one of the paths must be in the webspace (prefixed with ":")`)
+	case (srcRemote && src == "") || (dstRemote && dst == ""):
+		return errors.New("webspace path must not be empty")
+	}
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	if srcRemote {
+		return cpDownload(c, token, opts.User, src, dst, opts.AllowSymlinks)
+	}
+	return cpUpload(c, token, opts.User, src, dst)
+}
+
+// cpUploadScript extracts a tar archive of size $2 containing $3 to $1 (into $1 if it's a directory)
+const cpUploadScript = `
+dst=$1; size=$2; name=$3
+if [ -d "$dst" ]; then
+	echo OK
+	head -c "$size" | tar -xf - -C "$dst" 2>&1
+	exit
+fi
+
+parent=$(dirname -- "$dst")
+[ -d "$parent" ] || { echo "ERR $parent: no such directory"; exit 1; }
+tmp=$(mktemp -d "$parent/.netsoc-cp.XXXXXX") || { echo "ERR failed to create temporary directory"; exit 1; }
+echo OK
+head -c "$size" | tar -xf - -C "$tmp" 2>&1 && mv -f -- "$tmp/$name" "$dst" 2>&1
+s=$?; rm -rf -- "$tmp"; exit $s
+`
+
+// cpDownloadScript prints the approximate size of $1 (in KiB) and then a tar archive of it
+const cpDownloadScript = `
+src=$1
+[ -e "$src" ] || [ -L "$src" ] || { echo "ERR $src: no such file or directory"; exit 1; }
+size=$(du -sk -- "$src" 2>/dev/null | cut -f1)
+st=$(mktemp) || { echo "ERR failed to create temporary file"; exit 1; }
+echo "OK ${size:-0}"
+# tar refuses to write to a terminal, so pipe through cat (keeping its exit status)
+cd "$(dirname -- "$src")" && { tar -cf - "./$(basename -- "$src")" 2>/dev/null; echo $? >"$st"; } | cat
+s=$(cat "$st"); rm -f "$st"; exit "${s:-1}"
+`
+
+// readStatus reads the status line printed by a transfer script ("OK [info]" or "ERR <message>")
+func readStatus(s *util.ExecStream) (string, error) {
+	line, err := s.ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read status from webspace: %w", err)
+	}
+
+	switch {
+	case line == "OK":
+		return "", nil
+	case strings.HasPrefix(line, "OK "):
+		return line[3:], nil
+	case strings.HasPrefix(line, "ERR "):
+		return "", errors.New(line[4:])
+	default:
+		return "", fmt.Errorf("unexpected response from webspace: %q", line)
+	}
+}
+
+func cpUpload(c *config.Config, token, user, src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	// Build the archive first so we know how much the webspace should read
+	archive, err := ioutil.TempFile("", "netsoc-cp-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	name := info.Name()
+	if err := writeTar(archive, src, name); err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	s, err := util.StartExecStream(c, token, user, cpUploadScript, dst, strconv.FormatInt(size, 10), name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if _, err := readStatus(s); err != nil {
+		return err
+	}
+
+	await, t := util.TransferProgress(fmt.Sprintf("Uploading %v", src), size)
+	if _, err := io.Copy(s, io.TeeReader(archive, util.ProgressWriter{Tracker: t})); err != nil {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	out, code, err := s.Output()
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return err
+	}
+	if code != 0 {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to extract archive in webspace (exit code %v): %v", code, strings.TrimSpace(out))
+	}
+
+	t.MarkAsDone()
+	await()
+	log.Printf("Copied %v to %v", src, dst)
+	return nil
+}
+
+func cpDownload(c *config.Config, token, user, src, dst string, allowSymlinks bool) error {
+	// Copy into dst if it's a directory, otherwise rename the source to dst
+	dir, rename := dst, ""
+	if info, err := os.Stat(dst); err != nil || !info.IsDir() {
+		dir, rename = filepath.Dir(dst), filepath.Base(dst)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%v: no such directory", dir)
+		}
+	}
+
+	s, err := util.StartExecStream(c, token, user, cpDownloadScript, src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	sizeStr, err := readStatus(s)
+	if err != nil {
+		return err
+	}
+	size, _ := strconv.ParseInt(sizeStr, 10, 64)
+
+	await, t := util.TransferProgress(fmt.Sprintf("Downloading %v", src), size*1024)
+	if err := extractTar(io.TeeReader(s, util.ProgressWriter{Tracker: t}), dir, rename, allowSymlinks); err != nil {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	code, err := s.Wait()
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return err
+	}
+	if code != 0 {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to create archive in webspace (exit code %v)", code)
+	}
+
+	t.MarkAsDone()
+	await()
+	log.Printf("Copied %v to %v", src, filepath.Join(dir, rename))
+	return nil
+}
+
+// addTarEntry adds the file at p (a directory, regular file or symlink) to a tar archive as name. Files are owned by
+// root in the archive.
+func addTarEntry(tw *tar.Writer, p, name string, info os.FileInfo) error {
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	}
+
+	h, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	h.Name = name
+	if info.IsDir() {
+		h.Name += "/"
+	}
+	h.Uid, h.Gid = 0, 0
+	h.Uname, h.Gname = "root", "root"
+
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// writeTar writes a tar archive of src (a file or directory) with name as the top-level entry
+func writeTar(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		return addTarEntry(tw, p, path.Join(name, filepath.ToSlash(rel)), info)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// archiveName validates the name of a tar entry (which must stay inside the destination) and renames its top-level
+// component if rename is set
+func archiveName(name, rename string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("refusing to extract %q outside of destination", name)
+	}
+	if rename != "" {
+		parts := strings.SplitN(clean, "/", 2)
+		parts[0] = rename
+		clean = strings.Join(parts, "/")
+	}
+
+	return clean, nil
+}
+
+// checkInside makes sure p (following any symlinks in the part of it which exists) is inside root (which must have
+// its symlinks resolved already)
+func checkInside(root, p string) error {
+	// Resolve the deepest existing ancestor, the rest can't be a symlink (yet)
+	existing, rest := p, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			existing = filepath.Join(resolved, rest)
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return err
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+
+	rel, err := filepath.Rel(root, existing)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to extract %v outside of destination (through a symlink)", p)
+	}
+
+	return nil
+}
+
+// extractTar extracts a tar archive into dir, preserving permissions and modification times. If rename is set, the
+// top-level entry is renamed. Symlinks pointing outside of dir are rejected unless allowSymlinks is set (nothing is
+// ever written through them).
+func extractTar(r io.Reader, dir, rename string, allowSymlinks bool) error {
+	type dirTime struct {
+		path  string
+		mtime time.Time
+	}
+	var dirs []dirTime
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := archiveName(h.Name, rename)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		mode := os.FileMode(h.Mode).Perm()
+
+		// Directories are created (or reused) in place, anything else replaces what's at the target
+		check := filepath.Dir(target)
+		if h.Typeflag == tar.TypeDir {
+			check = target
+		}
+		if err := checkInside(root, check); err != nil {
+			return err
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+
+			// Directory times need to be set after their contents are written
+			dirs = append(dirs, dirTime{target, h.ModTime})
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+			// Remove whatever is there first so an existing (sym)link isn't written through
+			if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if !allowSymlinks {
+				dest := filepath.FromSlash(h.Linkname)
+				if !filepath.IsAbs(dest) {
+					dest = filepath.Join(filepath.Dir(target), dest)
+				}
+				if rel, err := filepath.Rel(root, dest); filepath.IsAbs(h.Linkname) || err != nil || rel == ".." ||
+					strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					return fmt.Errorf("refusing to extract symlink %q pointing outside of destination to %q "+
+						"(use --allow-symlinks to allow)", h.Name, h.Linkname)
+				}
+			}
+
+			os.Remove(target)
+			if err := os.Symlink(h.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			link, err := archiveName(h.Linkname, rename)
+			if err != nil {
+				return err
+			}
+			linkTarget := filepath.Join(root, filepath.FromSlash(link))
+			if err := checkInside(root, filepath.Dir(linkTarget)); err != nil {
+				return err
+			}
+
+			os.Remove(target)
+			if err := os.Link(linkTarget, target); err != nil {
+				return err
+			}
+			continue
+		default:
+			log.Printf("Skipping %v (unsupported file type)", h.Name)
+			continue
+		}
+
+		if err := os.Chtimes(target, h.ModTime, h.ModTime); err != nil {
+			return err
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].path, dirs[i].mtime, dirs[i].mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
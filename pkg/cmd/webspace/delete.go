@@ -2,18 +2,16 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type deleteOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	NoConfirm bool
@@ -23,7 +21,7 @@ type deleteOptions struct {
 // NewCmdDelete creates a new webspace delete command
 func NewCmdDelete(f *util.CmdFactory) *cobra.Command {
 	opts := deleteOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
@@ -42,15 +40,11 @@ func NewCmdDelete(f *util.CmdFactory) *cobra.Command {
 }
 
 func deleteRun(opts deleteOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	if !opts.NoConfirm {
 		shouldDelete, err := util.YesNo("Are you sure?", false)
 		if err != nil {
@@ -66,7 +60,7 @@ func deleteRun(opts deleteOptions) error {
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	await, _, t := util.SimpleProgress("Deleting webspace", 5*time.Second)
 	defer await()
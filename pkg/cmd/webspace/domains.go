@@ -2,33 +2,27 @@ package webspace
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"os"
-	"strings"
-	"text/template"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
-	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type domainsOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	OutputFormat string
-	User         string
+	Output printer.Options
+	Watch  printer.WatchOptions
+	User   string
 }
 
 // NewCmdDomains creates a new webspace domains command
 func NewCmdDomains(f *util.CmdFactory) *cobra.Command {
 	opts := domainsOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
@@ -39,70 +33,68 @@ func NewCmdDomains(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "output format `text|yaml|json|template=<Go template>`")
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptFormat(cmd, &opts.Output)
+	util.AddOptWatch(cmd, &opts.Watch)
+	util.AddOptProfileUser(cmd, &opts.User)
 
 	cmd.AddCommand(NewCmdDomainsAdd(f), NewCmdDomainsRemove(f))
 
 	return cmd
 }
 
-func printDomains(domains []string, outputType string) error {
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
-		}
-
-		if err := tpl.Execute(os.Stdout, domains); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
-		}
+// domainList is a list of webspace domains (registered separately from other string slices for table output)
+type domainList []string
 
-		return nil
-	}
+func init() {
+	printer.Register(domainList{}, printer.TableSpec{
+		Rows: func(data interface{}) []interface{} {
+			domains := data.(domainList)
+			rows := make([]interface{}, len(domains))
+			for i, d := range domains {
+				rows[i] = d
+			}
 
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(domains); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(domains); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	case "text":
-		// There will always be at least the default domain
-		fmt.Println("Webspace domains:")
-		for _, d := range domains {
-			fmt.Printf(" - %v\n", d)
-		}
-	default:
-		return fmt.Errorf(`unknown output format "%v"`, outputType)
-	}
-
-	return nil
+			return rows
+		},
+		Key: func(i interface{}) string {
+			return i.(string)
+		},
+		Columns: []printer.Column{
+			{Header: "Domain", Value: func(i interface{}) string {
+				return i.(string)
+			}},
+		},
+	})
 }
 
 func domainsRun(opts domainsOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
-	domains, _, err := client.DomainsApi.GetDomains(ctx, opts.User)
+	fetch := func() (interface{}, error) {
+		domains, _, err := client.DomainsApi.GetDomains(ctx, opts.User)
+		if err != nil {
+			return nil, util.APIError(err)
+		}
+
+		return domainList(domains), nil
+	}
+	if opts.Watch.Enabled {
+		return printer.Watch(fetch, opts.Output, opts.Watch)
+	}
+
+	domains, err := fetch()
 	if err != nil {
-		return util.APIError(err)
+		return err
 	}
 
-	return printDomains(domains, opts.OutputFormat)
+	return printer.Print(domains, opts.Output)
 }
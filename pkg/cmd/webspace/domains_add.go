@@ -2,19 +2,17 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"log"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type domainsAddOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User   string
@@ -24,7 +22,7 @@ type domainsAddOptions struct {
 // NewCmdDomainsAdd creates a new webspace domains add command
 func NewCmdDomainsAdd(f *util.CmdFactory) *cobra.Command {
 	opts := domainsAddOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -50,20 +48,16 @@ func NewCmdDomainsAdd(f *util.CmdFactory) *cobra.Command {
 }
 
 func domainsAddRun(opts domainsAddOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if _, err := client.DomainsApi.AddDomain(ctx, opts.User, opts.Domain); err != nil {
 		return util.APIError(err)
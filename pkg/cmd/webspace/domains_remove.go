@@ -2,18 +2,16 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"log"
 
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type domainsRemoveOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User   string
@@ -23,7 +21,7 @@ type domainsRemoveOptions struct {
 // NewCmdDomainsRemove creates a new webspace domains remove command
 func NewCmdDomainsRemove(f *util.CmdFactory) *cobra.Command {
 	opts := domainsRemoveOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -44,20 +42,16 @@ func NewCmdDomainsRemove(f *util.CmdFactory) *cobra.Command {
 }
 
 func domainsRemoveRun(opts domainsRemoveOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if _, err := client.DomainsApi.RemoveDomain(ctx, opts.User, opts.Domain); err != nil {
 		return util.APIError(err)
@@ -2,7 +2,6 @@ package webspace
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,32 +10,34 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
-	"text/template"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/containerd/console"
 	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
 	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type execOptions struct {
 	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	User         string
-	OutputFormat string
-	Request      webspaced.ExecInteractiveRequest
+	User    string
+	Output  printer.Options
+	Request webspaced.ExecInteractiveRequest
+	Record  string
 }
 
 // NewCmdExec creates a new webspace exec command
 func NewCmdExec(f *util.CmdFactory) *cobra.Command {
 	opts := execOptions{
 		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -51,20 +52,33 @@ func NewCmdExec(f *util.CmdFactory) *cobra.Command {
 			If this command does not run in a TTY, the remote command will run
 			non-interactively and the output will be YAML containing the
 			captured stdout, stderr and exit code. (Use --output to use a
-			different format)
+			different format) Passing --query also runs the command
+			non-interactively.
 
-			--uid, --gid, --env and --cwd only apply when running interactively.
+			--uid, --gid, --env, --cwd and --record only apply when running
+			interactively.
+
+			--record saves the session's output to a file in asciinema's
+			asciicast v2 format, which can be played back with "netsoc replay"
+			(or asciinema itself).
 		`),
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.Request.Command = args
 
-			if !util.IsInteractive() && opts.OutputFormat == "interactive" {
-				opts.OutputFormat = "yaml"
+			if opts.Output.Format == "interactive" {
+				switch {
+				case opts.Output.Query != "":
+					// Print query results as-is
+					opts.Output.Format = "table"
+				case !util.IsInteractive():
+					opts.Output.Format = "yaml"
+				}
 			}
-			if opts.OutputFormat != "interactive" {
-				if opts.Request.User != 0 || opts.Request.Group != 0 || len(env) != 0 || opts.Request.WorkingDirectory != "" {
-					return fmt.Errorf("uid, gid, env and cwd only apply to interactive exec")
+			if opts.Output.Format != "interactive" {
+				if opts.Request.User != 0 || opts.Request.Group != 0 || len(env) != 0 || opts.Request.WorkingDirectory != "" ||
+					opts.Record != "" {
+					return fmt.Errorf("uid, gid, env, cwd and record only apply to interactive exec")
 				}
 
 				return execSimple(opts)
@@ -84,58 +98,43 @@ func NewCmdExec(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptUser(cmd, &opts.User)
-	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "interactive", "output format `interactive|yaml|json|template=<Go template>`")
+	util.AddOptProfileUser(cmd, &opts.User)
+	printer.AddFlags(cmd, &opts.Output, "interactive", "interactive")
 	cmd.Flags().Int32Var(&opts.Request.User, "uid", 0, "webspace Linux user ID to run as")
 	cmd.Flags().Int32Var(&opts.Request.Group, "gid", 0, "webspace Linux group ID to run as")
 	cmd.Flags().StringArrayVarP(&env, "env", "e", []string{}, "environment variables to pass to command")
 	cmd.Flags().StringVar(&opts.Request.WorkingDirectory, "cwd", "", "webspace command working directory")
+	cmd.Flags().StringVar(&opts.Record, "record", "", "record the session to an asciicast `file`")
 
 	return cmd
 }
 
-func printSimple(result webspaced.ExecResponse, outputType string) error {
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
-		}
-
-		if err := tpl.Execute(os.Stdout, result); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
-		}
-
-		return nil
-	}
-
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(result); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	}
-
-	return nil
+func init() {
+	printer.Register(webspaced.ExecResponse{}, printer.TableSpec{
+		Columns: []printer.Column{
+			{Header: "Exit code", Value: func(i interface{}) string {
+				return fmt.Sprint(i.(webspaced.ExecResponse).ExitCode)
+			}},
+			{Header: "Stdout", Value: func(i interface{}) string {
+				return strings.TrimSuffix(i.(webspaced.ExecResponse).Stdout, "\n")
+			}},
+			{Header: "Stderr", Value: func(i interface{}) string {
+				return strings.TrimSuffix(i.(webspaced.ExecResponse).Stderr, "\n")
+			}},
+		},
+	})
 }
 func execSimple(opts execOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	result, _, err := client.ConsoleApi.Exec(ctx, opts.User, webspaced.ExecRequest{
 		Command: strings.Join(opts.Request.Command, " "),
@@ -144,7 +143,7 @@ func execSimple(opts execOptions) error {
 		return util.APIError(err)
 	}
 
-	return printSimple(result, opts.OutputFormat)
+	return printer.Print(result, opts.Output)
 }
 
 func execInteractive(opts execOptions) error {
@@ -153,11 +152,12 @@ func execInteractive(opts execOptions) error {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
-	conn, err := util.WebspacedWebsocket(c, opts.User, "exec")
+	conn, err := util.WebspacedWebsocket(c, token, opts.User, "exec")
 	if err != nil {
 		return fmt.Errorf("failed to open websocket connection: %w", err)
 	}
@@ -171,6 +171,17 @@ func execInteractive(opts execOptions) error {
 	opts.Request.Width = int32(s.Width)
 	opts.Request.Height = int32(s.Height)
 
+	var rec *util.Recorder
+	if opts.Record != "" {
+		rec, err = util.NewRecorder(opts.Record, util.ConsoleSize{Width: int(s.Width), Height: int(s.Height)},
+			strings.Join(opts.Request.Command, " "), map[string]string{"TERM": opts.Request.Environment["TERM"]})
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		defer closeRecorder(rec, opts.Record)
+	}
+
 	if err := conn.WriteJSON(opts.Request); err != nil {
 		conn.Close()
 		return fmt.Errorf("failed to send exec request: %w", err)
@@ -189,7 +200,7 @@ func execInteractive(opts execOptions) error {
 
 	errChan := make(chan error)
 	resizeChan := make(chan util.ConsoleSize)
-	signalChan := make(chan os.Signal)
+	signalChan := make(chan os.Signal, 1)
 	stopControl := make(chan struct{})
 
 	defer close(stopControl)
@@ -199,6 +210,10 @@ func execInteractive(opts execOptions) error {
 		for {
 			select {
 			case s := <-resizeChan:
+				if rec != nil {
+					rec.Resize(s)
+				}
+
 				rw.Mutex.Lock()
 
 				util.Debugf("Sending console resize: %v", s)
@@ -234,7 +249,11 @@ func execInteractive(opts execOptions) error {
 		errChan <- err
 	}
 	go pipe(rw, os.Stdin)
-	go pipe(os.Stdout, rw)
+	if rec != nil {
+		go pipe(os.Stdout, io.TeeReader(rw, rec))
+	} else {
+		go pipe(os.Stdout, rw)
+	}
 
 	var ce *websocket.CloseError
 	err = <-errChan
@@ -250,17 +269,30 @@ func execInteractive(opts execOptions) error {
 	return err
 }
 
+// closeRecorder finishes a session recording (after the terminal has been reset)
+func closeRecorder(rec *util.Recorder, file string) {
+	if err := rec.Close(); err != nil {
+		log.Print(err)
+		return
+	}
+
+	log.Printf("Session recorded to %v", file)
+}
+
 type loginOptions struct {
 	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	User string
+	User   string
+	Record string
 }
 
 // NewCmdLogin creates a new webspace login command
 func NewCmdLogin(f *util.CmdFactory) *cobra.Command {
 	opts := loginOptions{
 		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -272,26 +304,23 @@ func NewCmdLogin(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Record, "record", "", "record the session to an asciicast `file`")
 
 	return cmd
 }
 
 func runLogin(opts loginOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	result, _, err := client.ConsoleApi.Exec(ctx, opts.User, webspaced.ExecRequest{
 		Command: "getent passwd root",
@@ -314,13 +343,15 @@ func runLogin(opts loginOptions) error {
 
 	return execInteractive(execOptions{
 		Config:          opts.Config,
+		Token:           opts.Token,
 		WebspacedClient: opts.WebspacedClient,
 
-		User:         opts.User,
-		OutputFormat: "interactive",
+		User:   opts.User,
+		Output: printer.Options{Format: "interactive"},
 		Request: webspaced.ExecInteractiveRequest{
 			Command:     []string{shell},
 			Environment: map[string]string{"TERM": util.GetTERM()},
 		},
+		Record: opts.Record,
 	})
 }
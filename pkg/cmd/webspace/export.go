@@ -0,0 +1,92 @@
+package webspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+type exportOptions struct {
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	Users []string
+	Image string
+}
+
+// NewCmdExport creates a new webspace export command
+func NewCmdExport(f *util.CmdFactory) *cobra.Command {
+	opts := exportOptions{
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export webspace as manifest",
+		Long: heredoc.Doc(`
+			Print a manifest (for "netsoc webspace apply") describing the
+			current config, domains, port forwards and state of webspaces.
+			Multiple users can be given to export several webspaces.
+
+			Since the image of a webspace can't be retrieved, it's only
+			included if set with --image.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportRun(opts)
+		},
+	}
+
+	util.AddOptProfileUsers(cmd, &opts.Users)
+	cmd.Flags().StringVar(&opts.Image, "image", "", "`image` to include in the manifest")
+
+	return cmd
+}
+
+func exportRun(opts exportOptions) error {
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	for i, user := range opts.Users {
+		live, err := getLiveWebspace(ctx, client, user)
+		if err != nil {
+			return fmt.Errorf("failed to get webspace of user %v: %w", user, err)
+		}
+		if !live.Exists {
+			return fmt.Errorf("user %v doesn't have a webspace", user)
+		}
+
+		m := exportManifest(live)
+		m.Image = opts.Image
+		if user != "self" {
+			m.User = user
+		}
+
+		out, err := yaml.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to encode manifest: %w", err)
+		}
+
+		if i != 0 {
+			fmt.Println("---")
+		}
+		os.Stdout.Write(out)
+	}
+
+	return nil
+}
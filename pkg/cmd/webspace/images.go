@@ -2,17 +2,11 @@ package webspace
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os"
-	"strings"
-	"text/template"
 
 	"github.com/dustin/go-humanize"
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
@@ -20,7 +14,7 @@ import (
 type imagesOptions struct {
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	OutputFormat string
+	Output printer.Options
 }
 
 // NewCmdImages creates a new webspace images command
@@ -36,65 +30,35 @@ func NewCmdImages(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptFormat(cmd, &opts.OutputFormat)
+	util.AddOptFormat(cmd, &opts.Output)
 
 	return cmd
 }
 
-func printImages(images []webspaced.Image, outputType string) error {
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
-		}
-
-		if err := tpl.Execute(os.Stdout, images); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
-		}
-
-		return nil
-	}
-
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(images); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(images); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	case "table", "table-wide", "wide":
-		h := table.Row{"Alias", "Description", "Size"}
-		if outputType == "table-wide" || outputType == "wide" {
-			h = append(h, "Fingerprint")
-		}
-
-		t := table.NewWriter()
-		t.AppendHeader(h)
-		t.SetStyle(table.StyleRounded)
-
-		for _, i := range images {
-			alias := ""
-			if len(i.Aliases) > 0 {
-				alias = i.Aliases[0].Name
+func init() {
+	var (
+		alias = printer.Column{Header: "Alias", Value: func(i interface{}) string {
+			if aliases := i.(webspaced.Image).Aliases; len(aliases) > 0 {
+				return aliases[0].Name
 			}
 
-			description, _ := i.Properties["description"]
-
-			r := table.Row{alias, description, humanize.IBytes(uint64(i.Size))}
-			if outputType == "table-wide" || outputType == "wide" {
-				r = append(r, i.Fingerprint)
-			}
-			t.AppendRow(r)
-		}
-
-		fmt.Println(t.Render())
-	default:
-		return fmt.Errorf(`unknown output format "%v"`, outputType)
-	}
-
-	return nil
+			return ""
+		}}
+		description = printer.Column{Header: "Description", Value: func(i interface{}) string {
+			return i.(webspaced.Image).Properties["description"]
+		}}
+		size = printer.Column{Header: "Size", Value: func(i interface{}) string {
+			return humanize.IBytes(uint64(i.(webspaced.Image).Size))
+		}}
+		fingerprint = printer.Column{Header: "Fingerprint", Value: func(i interface{}) string {
+			return i.(webspaced.Image).Fingerprint
+		}}
+	)
+
+	printer.Register(webspaced.Image{}, printer.TableSpec{
+		Columns: []printer.Column{alias, description, size},
+		Wide:    []printer.Column{alias, description, size, fingerprint},
+	})
 }
 
 func imagesRun(opts imagesOptions) error {
@@ -109,5 +73,5 @@ func imagesRun(opts imagesOptions) error {
 		return util.APIError(err)
 	}
 
-	return printImages(images, opts.OutputFormat)
+	return printer.Print(images, opts.Output)
 }
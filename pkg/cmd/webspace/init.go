@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"time"
+	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -17,22 +17,27 @@ import (
 
 type initOptions struct {
 	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User       string
 	Image      string
+	Template   string
 	NoPassword bool
 	InstallSSH bool
+	Provision  []string
+	Wait       waitOptions
 }
 
 // NewCmdInit creates a new webspace init command
 func NewCmdInit(f *util.CmdFactory) *cobra.Command {
 	opts := initOptions{
 		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
-		Use:     "init <image>",
+		Use:     "init [image]",
 		Aliases: []string{"create"},
 		Short:   "Initialize webspace",
 		Long: heredoc.Doc(`
@@ -40,10 +45,23 @@ func NewCmdInit(f *util.CmdFactory) *cobra.Command {
 			default sets root password by reading from stdin. Can also install
 			an SSH server (providing an SSH key has been configured on the
 			user's account), along with a port forward.
-		`),
-		Args: cobra.ExactArgs(1),
+
+			With --provision, provisioning files are run in the webspace after
+			it's created (see "netsoc webspace provision").
+
+			With --template, the webspace is set up from a starter stack (see
+			"netsoc webspace templates list"). The template's image is used
+			unless one is given, and its provisioning steps are run before
+			any --provision files.
+		`) + waitHelp,
+		Args: cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts.Image = args[0]
+			if len(args) == 1 {
+				opts.Image = args[0]
+			} else if opts.Template == "" {
+				return errors.New("an image or template is required")
+			}
+
 			return initRun(opts)
 		},
 	}
@@ -51,25 +69,50 @@ func NewCmdInit(f *util.CmdFactory) *cobra.Command {
 	util.AddOptUser(cmd, &opts.User)
 	cmd.Flags().BoolVar(&opts.NoPassword, "no-password", false, "don't set root password")
 	cmd.Flags().BoolVarP(&opts.InstallSSH, "ssh", "s", false, "install SSH server")
+	cmd.Flags().StringVarP(&opts.Template, "template", "t", "", "`name` of template to set up webspace from")
+	cmd.Flags().StringArrayVar(&opts.Provision, "provision", []string{}, "provisioning `file` to run (can be repeated)")
+	addOptWait(cmd, &opts.Wait, true)
 
 	return cmd
 }
 
 func initRun(opts initOptions) error {
-	c, err := opts.Config()
+	probes, err := parseProbes(opts.Wait.For)
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	var tpl webspaceTemplate
+	var steps []provisionStep
+	if opts.Template != "" {
+		if tpl, err = findTemplate(opts.Template); err != nil {
+			return err
+		}
+		if opts.Image == "" {
+			opts.Image = tpl.Image
+		}
+
+		if steps, err = tpl.Provision.steps(); err != nil {
+			return err
+		}
+	}
+
+	files, err := loadProvisionFiles(opts.Provision)
+	if err != nil {
+		return err
+	}
+	steps = append(steps, files...)
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	var p string
 	if !opts.NoPassword {
@@ -85,7 +128,7 @@ func initRun(opts initOptions) error {
 		Ssh:      opts.InstallSSH,
 	}
 
-	await, _, t := util.SimpleProgress("Initializing webspace", 10*time.Second)
+	await, _, t := util.SimpleProgress("Initializing webspace", 0)
 
 	ws, _, err := client.ConfigApi.Create(ctx, opts.User, req)
 	t.MarkAsDone()
@@ -101,5 +144,54 @@ func initRun(opts initOptions) error {
 		}
 	}
 
+	if tpl.HTTPPort != 0 {
+		if err := patchConfig(ctx, client, token, opts.User, map[string]interface{}{
+			"httpPort": tpl.HTTPPort,
+		}); err != nil {
+			return fmt.Errorf("failed to set HTTP port: %w", err)
+		}
+	}
+	for _, i := range tpl.Ports {
+		p, _, err := client.PortsApi.AddRandomPort(ctx, opts.User, i)
+		if err != nil {
+			return fmt.Errorf("failed to forward port %v: %w", i, util.APIError(err))
+		}
+
+		log.Printf("Forwarded port %v to webspace port %v", p.EPort, i)
+	}
+
+	if len(steps) != 0 {
+		c, err := opts.Config()
+		if err != nil {
+			return err
+		}
+
+		path, err := provisionStatePath(c.ProfileName, opts.User)
+		if err != nil {
+			return err
+		}
+
+		if err := ensureRunning(client, token, opts.User, opts.Wait.Timeout); err != nil {
+			return err
+		}
+		if err := provision(c, token, &provisionState{
+			Profile: c.ProfileName,
+			User:    opts.User,
+			Steps:   steps,
+		}, path); err != nil {
+			return err
+		}
+	}
+
+	if opts.Wait.enabled() {
+		if err := waitForWebspace(client, token, opts.User, nil, opts.Wait, probes); err != nil {
+			return err
+		}
+	}
+
+	if tpl.Notes != "" {
+		log.Printf("\n%v", strings.TrimRight(tpl.Notes, "\n"))
+	}
+
 	return nil
 }
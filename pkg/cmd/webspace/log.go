@@ -2,19 +2,17 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type logOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User    string
@@ -24,7 +22,7 @@ type logOptions struct {
 // NewCmdLog creates a new webspace log command
 func NewCmdLog(f *util.CmdFactory) *cobra.Command {
 	opts := logOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
@@ -35,27 +33,23 @@ func NewCmdLog(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptProfileUser(cmd, &opts.User)
 	cmd.Flags().BoolVarP(&opts.IsClear, "clear", "c", false, "clear the console log instead of viewing it")
 
 	return cmd
 }
 
 func logRun(opts logOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if opts.IsClear {
 		if _, err := client.ConsoleApi.ClearLog(ctx, opts.User); err != nil {
@@ -0,0 +1,405 @@
+package webspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/mattn/go-isatty"
+	"gopkg.in/yaml.v2"
+
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// manifest describes the desired state of a webspace. Fields which are omitted aren't managed (e.g. if domains is
+// omitted, the webspace's domains are left alone). Domains and ports which are listed are added, but those which aren't
+// are only removed when pruning (so `domains: []` only removes all of the domains with --prune).
+type manifest struct {
+	// User is the user whose webspace is described (defaults to --user)
+	User string `yaml:"user,omitempty"`
+	// Image is only used if the webspace needs to be created (webspaced doesn't report the image of a webspace)
+	Image   string          `yaml:"image,omitempty"`
+	Config  *manifestConfig `yaml:"config,omitempty"`
+	Domains []string        `yaml:"domains"`
+	// Ports maps external ports to webspace ports
+	Ports   map[int]int32 `yaml:"ports"`
+	Running *bool         `yaml:"running,omitempty"`
+}
+
+type manifestConfig struct {
+	StartupDelay   *float64 `yaml:"startupDelay,omitempty"`
+	HTTPPort       *int32   `yaml:"httpPort,omitempty"`
+	SNIPassthrough *bool    `yaml:"sniPassthrough,omitempty"`
+}
+
+// readManifests reads the (possibly multi-document) manifest file at path ("-" for stdin), defaulting the user of
+// each webspace to user
+func readManifests(path, user string) ([]manifest, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	var manifests []manifest
+	users := map[string]bool{}
+	d := yaml.NewDecoder(r)
+	d.SetStrict(true)
+	for {
+		var m manifest
+		err := d.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+
+		if m.User == "" {
+			m.User = user
+		}
+		if users[m.User] {
+			return nil, fmt.Errorf("webspace of user %v is described more than once", m.User)
+		}
+		users[m.User] = true
+
+		for e, i := range m.Ports {
+			if e < 1 || e > 65535 || i < 1 || i > 65535 {
+				return nil, fmt.Errorf("invalid port forward %v -> %v for user %v", e, i, m.User)
+			}
+		}
+
+		manifests = append(manifests, m)
+	}
+	if len(manifests) == 0 {
+		return nil, errors.New("manifest is empty")
+	}
+
+	return manifests, nil
+}
+
+// liveWebspace is the current state of a webspace
+type liveWebspace struct {
+	Exists  bool
+	Config  webspaced.Config
+	Domains []string
+	Ports   map[string]int32
+	Running bool
+}
+
+func getLiveWebspace(ctx context.Context, client *webspaced.APIClient, user string) (liveWebspace, error) {
+	var live liveWebspace
+
+	config, res, err := client.ConfigApi.GetConfig(ctx, user)
+	if err != nil {
+		if notFound(res) {
+			return live, nil
+		}
+
+		return live, util.APIError(err)
+	}
+	live.Exists = true
+	live.Config = config
+
+	if live.Domains, _, err = client.DomainsApi.GetDomains(ctx, user); err != nil {
+		return live, util.APIError(err)
+	}
+	if live.Ports, _, err = client.PortsApi.GetPorts(ctx, user); err != nil {
+		return live, util.APIError(err)
+	}
+
+	state, _, err := client.StateApi.GetState(ctx, user)
+	if err != nil {
+		return live, util.APIError(err)
+	}
+	live.Running = state.Running
+
+	return live, nil
+}
+
+// exportManifest creates a manifest describing a live webspace
+func exportManifest(live liveWebspace) manifest {
+	m := manifest{
+		Config: &manifestConfig{
+			StartupDelay:   &live.Config.StartupDelay,
+			HTTPPort:       &live.Config.HttpPort,
+			SNIPassthrough: &live.Config.SniPassthrough,
+		},
+		Domains: append([]string{}, live.Domains...),
+		Ports:   map[int]int32{},
+		Running: &live.Running,
+	}
+	sort.Strings(m.Domains)
+	for e, i := range live.Ports {
+		if p, err := strconv.Atoi(e); err == nil {
+			m.Ports[p] = i
+		}
+	}
+
+	return m
+}
+
+// planStep is a single change to a webspace
+type planStep struct {
+	// Op is "+" (add), "-" (remove) or "~" (change)
+	Op          string
+	Description string
+
+	apply func(ctx context.Context) error
+}
+
+// webspacePlan is the list of changes needed to make a webspace match its manifest (in the order they should be made)
+type webspacePlan struct {
+	User  string
+	Steps []planStep
+	// Unmanaged is the number of domains and ports which aren't in the manifest (and will be removed with --prune)
+	Unmanaged int
+}
+
+// planWebspace computes the changes needed to make the live webspace match a manifest
+func planWebspace(client *webspaced.APIClient, token string, m manifest, live liveWebspace,
+	prune bool) (webspacePlan, error) {
+	plan := webspacePlan{User: m.User}
+	add := func(op, description string, apply func(ctx context.Context) error) {
+		plan.Steps = append(plan.Steps, planStep{op, description, apply})
+	}
+
+	if !live.Exists {
+		if m.Image == "" {
+			return plan, fmt.Errorf("webspace of user %v doesn't exist and manifest has no image to create it with",
+				m.User)
+		}
+
+		add("+", fmt.Sprintf("webspace (image %v)", m.Image), func(ctx context.Context) error {
+			_, _, err := client.ConfigApi.Create(ctx, m.User, webspaced.InitRequest{Image: m.Image})
+			return err
+		})
+	}
+
+	// Config
+	if m.Config != nil {
+		setConfig := func(option string, old, new interface{}) {
+			if live.Exists && old == new {
+				return
+			}
+
+			description := fmt.Sprintf("config.%v: %v -> %v", option, old, new)
+			if !live.Exists {
+				description = fmt.Sprintf("config.%v: %v", option, new)
+			}
+			add("~", description, func(ctx context.Context) error {
+				return patchConfig(ctx, client, token, m.User, map[string]interface{}{option: new})
+			})
+		}
+
+		if m.Config.StartupDelay != nil {
+			setConfig("startupDelay", live.Config.StartupDelay, *m.Config.StartupDelay)
+		}
+		if m.Config.HTTPPort != nil {
+			setConfig("httpPort", live.Config.HttpPort, *m.Config.HTTPPort)
+		}
+		if m.Config.SNIPassthrough != nil {
+			setConfig("sniPassthrough", live.Config.SniPassthrough, *m.Config.SNIPassthrough)
+		}
+	}
+
+	// Removals come first so that changed port forwards (and domains moved between webspaces) are freed up
+	var addPorts []int
+	if m.Ports != nil {
+		var external []string
+		for e := range live.Ports {
+			external = append(external, e)
+		}
+		sort.Slice(external, func(i, j int) bool {
+			a, _ := strconv.Atoi(external[i])
+			b, _ := strconv.Atoi(external[j])
+			return a < b
+		})
+
+		for _, e := range external {
+			p, _ := strconv.Atoi(e)
+			i := live.Ports[e]
+			wanted, ok := m.Ports[p]
+			if ok && wanted == i {
+				continue
+			}
+			if !ok && !prune {
+				plan.Unmanaged++
+				continue
+			}
+
+			add("-", fmt.Sprintf("port %v -> %v", p, i), func(ctx context.Context) error {
+				_, err := client.PortsApi.RemovePort(ctx, m.User, int32(p))
+				return err
+			})
+		}
+
+		for e, i := range m.Ports {
+			if current, ok := live.Ports[strconv.Itoa(e)]; !ok || current != i {
+				addPorts = append(addPorts, e)
+			}
+		}
+		sort.Ints(addPorts)
+	}
+
+	var addDomains []string
+	if m.Domains != nil {
+		wanted := map[string]bool{}
+		for _, d := range m.Domains {
+			wanted[d] = true
+		}
+		current := map[string]bool{}
+		for _, d := range live.Domains {
+			current[d] = true
+		}
+
+		removed := append([]string{}, live.Domains...)
+		sort.Strings(removed)
+		for _, d := range removed {
+			if wanted[d] {
+				continue
+			}
+			if !prune {
+				plan.Unmanaged++
+				continue
+			}
+
+			d := d
+			add("-", "domain "+d, func(ctx context.Context) error {
+				_, err := client.DomainsApi.RemoveDomain(ctx, m.User, d)
+				return err
+			})
+		}
+
+		for _, d := range m.Domains {
+			if !current[d] {
+				addDomains = append(addDomains, d)
+			}
+		}
+		sort.Strings(addDomains)
+	}
+
+	for _, d := range addDomains {
+		d := d
+		add("+", "domain "+d, func(ctx context.Context) error {
+			_, err := client.DomainsApi.AddDomain(ctx, m.User, d)
+			return err
+		})
+	}
+	for _, e := range addPorts {
+		e, i := e, m.Ports[e]
+		add("+", fmt.Sprintf("port %v -> %v", e, i), func(ctx context.Context) error {
+			_, err := client.PortsApi.AddPort(ctx, m.User, int32(e), i)
+			return err
+		})
+	}
+
+	// State
+	if m.Running != nil && *m.Running != live.Running {
+		if *m.Running {
+			add("~", "running: false -> true", func(ctx context.Context) error {
+				_, err := client.StateApi.Start(ctx, m.User)
+				return err
+			})
+		} else {
+			add("~", "running: true -> false", func(ctx context.Context) error {
+				_, err := client.StateApi.Shutdown(ctx, m.User)
+				return err
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// printPlans prints the changes in a set of plans as a diff
+func printPlans(w io.Writer, plans []webspacePlan) {
+	colors := map[string]text.Colors{
+		"+": {text.FgGreen},
+		"-": {text.FgRed},
+		"~": {text.FgYellow},
+	}
+	if f, ok := w.(*os.File); !ok || !isatty.IsTerminal(f.Fd()) {
+		colors = map[string]text.Colors{}
+	}
+
+	for _, p := range plans {
+		if len(p.Steps) == 0 {
+			fmt.Fprintf(w, "webspace %v: no changes\n", p.User)
+		} else {
+			fmt.Fprintf(w, "webspace %v:\n", p.User)
+		}
+
+		for _, s := range p.Steps {
+			fmt.Fprintf(w, "  %v\n", colors[s.Op].Sprintf("%v %v", s.Op, s.Description))
+		}
+		if p.Unmanaged != 0 {
+			fmt.Fprintf(w, "  (%v domains/ports not in the manifest, use --prune to remove)\n", p.Unmanaged)
+		}
+	}
+}
+
+// patchConfig updates webspace config options. ConfigApi.UpdateConfig omits zero values (so e.g. sniPassthrough can't
+// be disabled), so the request is made directly.
+func patchConfig(ctx context.Context, client *webspaced.APIClient, token, user string,
+	patch map[string]interface{}) error {
+	cfg := client.GetConfig()
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch,
+		cfg.BasePath+"/webspace/"+url.PathEscape(user)+"/config", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range cfg.DefaultHeader {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 300 {
+		return nil
+	}
+
+	message := res.Status
+	var apiErr webspaced.Error
+	if err := json.NewDecoder(res.Body).Decode(&apiErr); err == nil && apiErr.Message != "" {
+		message = apiErr.Message
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		return &util.UnauthorizedError{Message: message}
+	}
+
+	return errors.New(message)
+}
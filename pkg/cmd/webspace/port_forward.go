@@ -0,0 +1,235 @@
+package webspace
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type portForwardOptions struct {
+	Config func() (*config.Config, error)
+	Token  func() (string, error)
+
+	User     string
+	Address  string
+	Forwards []string
+}
+
+// NewCmdPortForward creates a new webspace port-forward command
+func NewCmdPortForward(f *util.CmdFactory) *cobra.Command {
+	opts := portForwardOptions{
+		Config: f.Config,
+		Token:  f.Token,
+	}
+	cmd := &cobra.Command{
+		Use:   "port-forward [local:]remote...",
+		Short: "Forward local ports to webspace",
+		Long: heredoc.Doc(`
+			Listen on local ports and forward connections to ports inside a
+			webspace (e.g. to reach a database or admin panel which isn't
+			exposed publicly). If the local port is omitted, the remote port
+			number is used. Unlike "webspace ports add", nothing is exposed
+			publicly.
+
+			Each connection is relayed through socat or nc in the webspace,
+			one of which must be installed.
+		`),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Forwards = args
+			return portForwardRun(opts)
+		},
+	}
+
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Address, "address", "127.0.0.1", "local `address` to listen on")
+
+	return cmd
+}
+
+// relayScript connects stdin and stdout to TCP port $2 on host $1
+const relayScript = `
+if command -v socat >/dev/null 2>&1; then
+	echo OK; exec socat - "TCP:$1:$2" 2>/dev/null
+elif command -v nc >/dev/null 2>&1; then
+	echo OK; exec nc "$1" "$2" 2>/dev/null
+fi
+echo "ERR neither socat nor nc is installed in the webspace"; exit 1
+`
+
+// webspaceDial opens a TCP connection from inside a webspace (over the exec websocket)
+func webspaceDial(c *config.Config, token, user, host string, port int) (*util.ExecStream, error) {
+	s, err := util.StartExecStream(c, token, user, relayScript, host, strconv.Itoa(port))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := readStatus(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// relay copies data between a local connection and a webspace stream until either side is done
+func relay(conn net.Conn, s *util.ExecStream) {
+	var once sync.Once
+	done := make(chan struct{})
+	finish := func() {
+		once.Do(func() { close(done) })
+	}
+
+	go func() {
+		io.Copy(s, conn)
+		finish()
+	}()
+	go func() {
+		io.Copy(conn, s)
+		finish()
+	}()
+
+	<-done
+	conn.Close()
+	s.Close()
+}
+
+// parsePortForward parses `[local:]remote`
+func parsePortForward(spec string) (int, int, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("invalid port forward %q (expected [local:]remote)", spec)
+	}
+
+	ports := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 65535 || (n == 0 && i == len(parts)-1) {
+			return 0, 0, fmt.Errorf("invalid port %q in %q", p, spec)
+		}
+
+		ports[i] = n
+	}
+
+	if len(ports) == 1 {
+		return ports[0], ports[0], nil
+	}
+	return ports[0], ports[1], nil
+}
+
+func portForwardRun(opts portForwardOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	var (
+		listeners []net.Listener
+		wg        sync.WaitGroup
+
+		mu     sync.Mutex
+		closed bool
+		active = map[net.Conn]struct{}{}
+	)
+	closeAll := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		closed = true
+		for _, l := range listeners {
+			l.Close()
+		}
+		for conn := range active {
+			conn.Close()
+		}
+	}
+	defer closeAll()
+
+	for _, spec := range opts.Forwards {
+		local, remote, err := parsePortForward(spec)
+		if err != nil {
+			return err
+		}
+
+		l, err := net.Listen("tcp", net.JoinHostPort(opts.Address, strconv.Itoa(local)))
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+		listeners = append(listeners, l)
+		log.Printf("Forwarding from %v -> %v", l.Addr(), remote)
+
+		wg.Add(1)
+		go func(l net.Listener, remote int) {
+			defer wg.Done()
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					mu.Lock()
+					if !closed {
+						log.Printf("Failed to accept connection: %v", err)
+					}
+					mu.Unlock()
+					return
+				}
+
+				mu.Lock()
+				if closed {
+					mu.Unlock()
+					conn.Close()
+					return
+				}
+				active[conn] = struct{}{}
+				mu.Unlock()
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() {
+						mu.Lock()
+						delete(active, conn)
+						mu.Unlock()
+					}()
+
+					log.Printf("Handling connection for %v", l.Addr())
+					s, err := webspaceDial(c, token, opts.User, "127.0.0.1", remote)
+					if err != nil {
+						log.Printf("Failed to connect to port %v in webspace: %v", remote, err)
+						conn.Close()
+						return
+					}
+
+					relay(conn, s)
+				}()
+			}
+		}(l, remote)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	<-sigs
+	log.Print("Shutting down")
+	closeAll()
+	wg.Wait()
+
+	return nil
+}
@@ -2,33 +2,30 @@ package webspace
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
-	"strings"
-	"text/template"
+	"sort"
+	"strconv"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
-	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type portsOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	OutputFormat string
-	User         string
+	Output printer.Options
+	Watch  printer.WatchOptions
+	User   string
 }
 
 // NewCmdPorts creates a new webspace ports command
 func NewCmdPorts(f *util.CmdFactory) *cobra.Command {
 	opts := portsOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
@@ -39,69 +36,84 @@ func NewCmdPorts(f *util.CmdFactory) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "output format `text|yaml|json|template=<Go template>`")
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptFormat(cmd, &opts.Output)
+	util.AddOptWatch(cmd, &opts.Watch)
+	util.AddOptProfileUser(cmd, &opts.User)
 
 	cmd.AddCommand(NewCmdPortsAdd(f), NewCmdPortsRemove(f))
 
 	return cmd
 }
 
-func printPorts(ports map[string]int32, outputType string) error {
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
-		}
-
-		if err := tpl.Execute(os.Stdout, ports); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
-		}
-
-		return nil
-	}
+// portForwards maps external ports to webspace ports
+type portForwards map[string]int32
 
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(ports); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(ports); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	case "text":
-		fmt.Println("Webspace port forwards:")
-		for i, e := range ports {
-			fmt.Printf(" - %v -> %v\n", i, e)
-		}
-	default:
-		return fmt.Errorf(`unknown output format "%v"`, outputType)
-	}
+type portForward struct {
+	External int   `json:"external" yaml:"external"`
+	Internal int32 `json:"internal" yaml:"internal"`
+}
 
-	return nil
+func init() {
+	printer.Register(portForwards{}, printer.TableSpec{
+		Rows: func(data interface{}) []interface{} {
+			ports := data.(portForwards)
+			forwards := make([]portForward, 0, len(ports))
+			for e, i := range ports {
+				external, _ := strconv.Atoi(e)
+				forwards = append(forwards, portForward{External: external, Internal: i})
+			}
+			sort.Slice(forwards, func(i, j int) bool {
+				return forwards[i].External < forwards[j].External
+			})
+
+			rows := make([]interface{}, len(forwards))
+			for i, f := range forwards {
+				rows[i] = f
+			}
+			return rows
+		},
+		Key: func(i interface{}) string {
+			return strconv.Itoa(i.(portForward).External)
+		},
+		Columns: []printer.Column{
+			{Header: "External port", Value: func(i interface{}) string {
+				return strconv.Itoa(i.(portForward).External)
+			}},
+			{Header: "Webspace port", Value: func(i interface{}) string {
+				return fmt.Sprint(i.(portForward).Internal)
+			}},
+		},
+	})
 }
 
 func portsRun(opts portsOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
-	ports, _, err := client.PortsApi.GetPorts(ctx, opts.User)
+	fetch := func() (interface{}, error) {
+		ports, _, err := client.PortsApi.GetPorts(ctx, opts.User)
+		if err != nil {
+			return nil, util.APIError(err)
+		}
+
+		return portForwards(ports), nil
+	}
+	if opts.Watch.Enabled {
+		return printer.Watch(fetch, opts.Output, opts.Watch)
+	}
+
+	ports, err := fetch()
 	if err != nil {
-		return util.APIError(err)
+		return err
 	}
 
-	return printPorts(ports, opts.OutputFormat)
+	return printer.Print(ports, opts.Output)
 }
@@ -2,19 +2,17 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"strconv"
 
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type portsAddOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User         string
@@ -25,7 +23,7 @@ type portsAddOptions struct {
 // NewCmdPortsAdd creates a new webspace ports add command
 func NewCmdPortsAdd(f *util.CmdFactory) *cobra.Command {
 	opts := portsAddOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -51,20 +49,16 @@ func NewCmdPortsAdd(f *util.CmdFactory) *cobra.Command {
 }
 
 func portsAddRun(opts portsAddOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if opts.ExternalPort == 0 {
 		i, _, err := client.PortsApi.AddRandomPort(ctx, opts.User, int32(opts.InternalPort))
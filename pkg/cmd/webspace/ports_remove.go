@@ -2,20 +2,18 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"strconv"
 
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type portsRemoveOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User string
@@ -25,7 +23,7 @@ type portsRemoveOptions struct {
 // NewCmdPortsRemove creates a new webspace ports remove command
 func NewCmdPortsRemove(f *util.CmdFactory) *cobra.Command {
 	opts := portsRemoveOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 
@@ -51,20 +49,16 @@ func NewCmdPortsRemove(f *util.CmdFactory) *cobra.Command {
 }
 
 func portsRemoveRun(opts portsRemoveOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	if _, err := client.PortsApi.RemovePort(ctx, opts.User, int32(opts.Port)); err != nil {
 		return util.APIError(err)
@@ -0,0 +1,446 @@
+package webspace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// provisionHelp describes provisioning files
+const provisionHelp = `
+Provisioning files can be shell scripts (run with sh unless they start with
+#!) or YAML files (ending in .yaml or .yml, or starting with #cloud-config)
+similar to cloud-init's:
+
+  packages: [nginx, git]
+  users:
+    - name: deploy
+      groups: [sudo]
+      shell: /bin/bash
+      ssh_authorized_keys: [ssh-ed25519 AAAA...]
+  files:
+    - path: /etc/motd
+      content: |
+        Welcome!
+      permissions: "0644"
+      owner: root:root
+  commands:
+    - systemctl enable --now nginx
+
+Each package list, user, file and command is a separate step. Steps run in
+order, stopping at the first failure. Completed steps are recorded so that
+"netsoc webspace provision --resume" can continue from the failed step.
+`
+
+// provisionConfig is a cloud-init-like provisioning file
+type provisionConfig struct {
+	Packages []string `yaml:"packages,omitempty" json:"packages,omitempty"`
+	Users    []struct {
+		Name              string   `yaml:"name" json:"name"`
+		Groups            []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+		Shell             string   `yaml:"shell,omitempty" json:"shell,omitempty"`
+		SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty" json:"ssh_authorized_keys,omitempty"`
+	} `yaml:"users,omitempty" json:"users,omitempty"`
+	Files []struct {
+		Path        string `yaml:"path" json:"path"`
+		Content     string `yaml:"content" json:"content"`
+		Permissions string `yaml:"permissions,omitempty" json:"permissions,omitempty"`
+		Owner       string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	} `yaml:"files,omitempty" json:"files,omitempty"`
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"`
+}
+
+// provisionStep is a shell script (with arguments) run in a webspace. Input is written to the script's stdin, which
+// is used for file contents and scripts since arguments are limited in size.
+type provisionStep struct {
+	Name   string   `json:"name"`
+	Script string   `json:"script"`
+	Args   []string `json:"args,omitempty"`
+	Input  string   `json:"input,omitempty"`
+}
+
+// provisionScriptStep runs the script of $1 bytes read from stdin
+const provisionScriptStep = `
+f=$(mktemp) || exit
+head -c "$1" >"$f" && chmod 700 "$f" || { rm -f "$f"; exit 1; }
+[ "$(wc -c <"$f")" -eq "$1" ] || { rm -f "$f"; echo "script was cut short" >&2; exit 1; }
+case "$(head -c 2 "$f")" in
+	'#!') "$f" ;;
+	*) sh "$f" ;;
+esac
+s=$?; rm -f "$f"; exit $s
+`
+
+// provisionPackagesStep installs packages with whichever package manager is available
+const provisionPackagesStep = `
+if command -v apt-get >/dev/null 2>&1; then
+	export DEBIAN_FRONTEND=noninteractive
+	apt-get update && apt-get install -y "$@"
+elif command -v apk >/dev/null 2>&1; then
+	apk add --no-cache "$@"
+elif command -v dnf >/dev/null 2>&1; then
+	dnf install -y "$@"
+elif command -v yum >/dev/null 2>&1; then
+	yum install -y "$@"
+elif command -v pacman >/dev/null 2>&1; then
+	pacman -Sy --noconfirm --needed "$@"
+else
+	echo "no supported package manager found" >&2; exit 1
+fi
+`
+
+// provisionUserStep creates user $1 (if it doesn't exist) with shell $2, adds it to the comma-separated groups in $3
+// and adds the SSH keys in $4 (one per line)
+const provisionUserStep = `
+name=$1; shell=$2; groups=$3; keys=$4
+if ! id "$name" >/dev/null 2>&1; then
+	if command -v useradd >/dev/null 2>&1; then
+		useradd -m ${shell:+-s "$shell"} "$name"
+	else
+		adduser -D ${shell:+-s "$shell"} "$name"
+	fi || exit
+elif [ -n "$shell" ]; then
+	chsh -s "$shell" "$name" 2>/dev/null || usermod -s "$shell" "$name" || exit
+fi
+for g in $(echo "$groups" | tr ',' ' '); do
+	if command -v usermod >/dev/null 2>&1; then usermod -aG "$g" "$name"; else addgroup "$name" "$g"; fi || exit
+done
+[ -n "$keys" ] || exit 0
+home=$(getent passwd "$name" | cut -d: -f6)
+mkdir -p "$home/.ssh" && touch "$home/.ssh/authorized_keys" || exit
+echo "$keys" | while IFS= read -r k; do
+	grep -qxF "$k" "$home/.ssh/authorized_keys" || echo "$k" >>"$home/.ssh/authorized_keys"
+done
+chmod 700 "$home/.ssh" && chmod 600 "$home/.ssh/authorized_keys" && chown -R "$name:" "$home/.ssh"
+`
+
+// provisionFileStep writes $2 bytes read from stdin to the file $1, optionally setting its permissions ($3) and owner
+// ($4)
+const provisionFileStep = `
+mkdir -p "$(dirname -- "$1")" && head -c "$2" >"$1" || exit
+[ "$(wc -c <"$1")" -eq "$2" ] || { echo "contents were cut short" >&2; exit 1; }
+[ -z "$3" ] || chmod "$3" "$1" || exit
+[ -z "$4" ] || chown "$4" "$1"
+`
+
+// loadProvisionSteps reads a provisioning file (a shell script or YAML config) and converts it to steps
+func loadProvisionSteps(path string) ([]provisionStep, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" && !bytes.HasPrefix(data, []byte("#cloud-config")) {
+		return []provisionStep{{
+			Name:   "script " + filepath.Base(path),
+			Script: provisionScriptStep,
+			Args:   []string{strconv.Itoa(len(data))},
+			Input:  string(data),
+		}}, nil
+	}
+
+	var pc provisionConfig
+	if err := yaml.UnmarshalStrict(data, &pc); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	}
+
+	steps, err := pc.steps()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	return steps, nil
+}
+
+// steps converts a provisioning config to steps
+func (pc provisionConfig) steps() ([]provisionStep, error) {
+	var steps []provisionStep
+	if len(pc.Packages) != 0 {
+		steps = append(steps, provisionStep{
+			Name:   "packages " + strings.Join(pc.Packages, " "),
+			Script: provisionPackagesStep,
+			Args:   pc.Packages,
+		})
+	}
+	for _, u := range pc.Users {
+		if u.Name == "" {
+			return nil, errors.New("user without a name")
+		}
+
+		steps = append(steps, provisionStep{
+			Name:   "user " + u.Name,
+			Script: provisionUserStep,
+			Args:   []string{u.Name, u.Shell, strings.Join(u.Groups, ","), strings.Join(u.SSHAuthorizedKeys, "\n")},
+		})
+	}
+	for _, f := range pc.Files {
+		if f.Path == "" {
+			return nil, errors.New("file without a path")
+		}
+
+		steps = append(steps, provisionStep{
+			Name:   "file " + f.Path,
+			Script: provisionFileStep,
+			Args:   []string{f.Path, strconv.Itoa(len(f.Content)), f.Permissions, f.Owner},
+			Input:  f.Content,
+		})
+	}
+	for _, c := range pc.Commands {
+		name := strings.SplitN(c, "\n", 2)[0]
+		if len(name) > 60 {
+			name = name[:57] + "..."
+		}
+
+		steps = append(steps, provisionStep{
+			Name:   "command " + name,
+			Script: provisionScriptStep,
+			Args:   []string{strconv.Itoa(len(c))},
+			Input:  c,
+		})
+	}
+
+	return steps, nil
+}
+
+// loadProvisionFiles loads steps from a list of provisioning files
+func loadProvisionFiles(paths []string) ([]provisionStep, error) {
+	var steps []provisionStep
+	for _, p := range paths {
+		s, err := loadProvisionSteps(p)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, s...)
+	}
+
+	return steps, nil
+}
+
+// provisionState records the progress of provisioning a webspace
+type provisionState struct {
+	Profile   string          `json:"profile"`
+	User      string          `json:"user"`
+	Steps     []provisionStep `json:"steps"`
+	Completed int             `json:"completed"`
+	Updated   time.Time       `json:"updated"`
+}
+
+// provisionStatePath returns the path of the file where provisioning progress is recorded
+func provisionStatePath(profile, user string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "netsoc", "provision", profile+"."+user+".json"), nil
+}
+
+func (s *provisionState) save(path string) error {
+	s.Updated = time.Now()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0o600)
+}
+
+// runProvisionStep runs a step in a webspace, streaming its output to w
+func runProvisionStep(c *config.Config, token, user string, step provisionStep, w io.Writer) error {
+	s, err := util.StartExecStream(c, token, user, step.Script, step.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if step.Input != "" {
+		if _, err := io.WriteString(s, step.Input); err != nil {
+			return err
+		}
+	}
+
+	if _, err := io.Copy(w, s); err != nil {
+		return err
+	}
+
+	code, err := s.Wait()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exited with code %v", code)
+	}
+
+	return nil
+}
+
+// provision runs the remaining provisioning steps in state, recording progress in the file at path
+func provision(c *config.Config, token string, state *provisionState, path string) error {
+	if err := state.save(path); err != nil {
+		return fmt.Errorf("failed to save provisioning state: %w", err)
+	}
+
+	total := len(state.Steps)
+	for state.Completed < total {
+		step := state.Steps[state.Completed]
+		log.Printf("[%v/%v] %v", state.Completed+1, total, step.Name)
+
+		start := time.Now()
+		if err := runProvisionStep(c, token, state.User, step, os.Stdout); err != nil {
+			return fmt.Errorf("provisioning step %v (%v) failed: %w (run `netsoc webspace provision --resume` to "+
+				"continue)", state.Completed+1, step.Name, err)
+		}
+
+		state.Completed++
+		if err := state.save(path); err != nil {
+			return fmt.Errorf("failed to save provisioning state: %w", err)
+		}
+		log.Printf("[%v/%v] %v: done (%v)", state.Completed, total, step.Name,
+			time.Since(start).Round(time.Millisecond))
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove provisioning state: %w", err)
+	}
+
+	log.Printf("Provisioned webspace (%v steps)", total)
+	return nil
+}
+
+// ensureRunning starts a webspace if it isn't running and waits for it to have network addresses
+func ensureRunning(client *webspaced.APIClient, token, user string, timeout time.Duration) error {
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	state, _, err := client.StateApi.GetState(ctx, user)
+	if err != nil {
+		return util.APIError(err)
+	}
+	if !state.Running {
+		if _, err := client.StateApi.Start(ctx, user); err != nil {
+			return util.APIError(err)
+		}
+	}
+
+	running := true
+	return waitForWebspace(client, token, user, &running, waitOptions{Timeout: timeout}, nil)
+}
+
+type provisionOptions struct {
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User    string
+	Files   []string
+	Resume  bool
+	Timeout time.Duration
+}
+
+// NewCmdProvision creates a new webspace provision command
+func NewCmdProvision(f *util.CmdFactory) *cobra.Command {
+	opts := provisionOptions{
+		Config:          f.Config,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "provision [file...]",
+		Short: "Provision webspace",
+		Long: heredoc.Doc(`
+			Run provisioning files in a webspace (see also "netsoc webspace
+			init --provision"), starting it first if necessary. Output from
+			each step is shown as it runs. With --resume, provisioning which
+			previously failed is continued from the step that failed.
+		`) + provisionHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Files = args
+			switch {
+			case opts.Resume && len(args) != 0:
+				return errors.New("provisioning files can't be given with --resume")
+			case !opts.Resume && len(args) == 0:
+				return errors.New("no provisioning files given")
+			}
+
+			return provisionRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().BoolVar(&opts.Resume, "resume", false, "continue provisioning which previously failed")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait for the webspace to start")
+
+	return cmd
+}
+
+func provisionRun(opts provisionOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	path, err := provisionStatePath(c.ProfileName, opts.User)
+	if err != nil {
+		return err
+	}
+
+	state := &provisionState{
+		Profile: c.ProfileName,
+		User:    opts.User,
+	}
+	if opts.Resume {
+		data, err := ioutil.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no provisioning to resume for user %v", opts.User)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("failed to parse provisioning state: %w", err)
+		}
+		log.Printf("Resuming provisioning at step %v/%v", state.Completed+1, len(state.Steps))
+	} else if state.Steps, err = loadProvisionFiles(opts.Files); err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+
+	if err := ensureRunning(client, token, opts.User, opts.Timeout); err != nil {
+		return err
+	}
+
+	return provision(c, token, state, path)
+}
@@ -0,0 +1,361 @@
+package webspace
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+type proxyOptions struct {
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User   string
+	Listen string
+	Allow  []string
+}
+
+// NewCmdProxy creates a new webspace proxy command
+func NewCmdProxy(f *util.CmdFactory) *cobra.Command {
+	opts := proxyOptions{
+		Config:          f.Config,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Run a SOCKS5 proxy into webspace",
+		Long: heredoc.Doc(`
+			Run a local SOCKS5 proxy which makes connections from inside a
+			webspace, e.g. to reach services listening on the webspace's
+			private addresses from a browser. Only CONNECT is supported.
+
+			By default, only the webspace's own networks and loopback are
+			allowed as targets. Use --allow to set the allowed CIDRs instead
+			(0.0.0.0/0 allows everything). Since hostnames are resolved in
+			the webspace, targets given as hostnames are only allowed if a
+			CIDR covering all addresses (0.0.0.0/0 or ::/0) is given.
+
+			Connections are logged with --debug. Each connection is relayed
+			through socat or nc in the webspace, one of which must be
+			installed.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return proxyRun(opts)
+		},
+	}
+
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Listen, "listen", "127.0.0.1:1080", "local `address` to listen on")
+	cmd.Flags().StringSliceVar(&opts.Allow, "allow", []string{}, "`CIDR`s targets are allowed in (can be repeated)")
+
+	return cmd
+}
+
+// SOCKS5 protocol constants (RFC 1928)
+const (
+	socksVersion = 5
+
+	socksNoAuth       = 0
+	socksNoAcceptable = 0xff
+
+	socksConnect = 1
+
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+
+	socksSucceeded           = 0
+	socksGeneralFailure      = 1
+	socksNotAllowed          = 2
+	socksCommandNotSupported = 7
+	socksAddressNotSupported = 8
+)
+
+// socksHandshake negotiates a SOCKS5 CONNECT request, returning the target host and port
+func socksHandshake(conn net.Conn) (string, int, error) {
+	// Large enough for any length prefixed field (which have a 1 byte length) along with the 2 bytes before it
+	buf := make([]byte, 2+255)
+
+	// Version and authentication methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", 0, err
+	}
+	if buf[0] != socksVersion {
+		return "", 0, fmt.Errorf("unsupported SOCKS version %v", buf[0])
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", 0, err
+	}
+
+	noAuth := false
+	for _, m := range methods {
+		if m == socksNoAuth {
+			noAuth = true
+		}
+	}
+	if !noAuth {
+		conn.Write([]byte{socksVersion, socksNoAcceptable})
+		return "", 0, errors.New("client doesn't support unauthenticated SOCKS")
+	}
+	if _, err := conn.Write([]byte{socksVersion, socksNoAuth}); err != nil {
+		return "", 0, err
+	}
+
+	// Request
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return "", 0, err
+	}
+	if buf[1] != socksConnect {
+		socksReply(conn, socksCommandNotSupported)
+		return "", 0, fmt.Errorf("unsupported SOCKS command %v", buf[1])
+	}
+
+	var host string
+	switch buf[3] {
+	case socksIPv4, socksIPv6:
+		n := net.IPv4len
+		if buf[3] == socksIPv6 {
+			n = net.IPv6len
+		}
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return "", 0, err
+		}
+
+		host = net.IP(buf[:n]).String()
+	case socksDomain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return "", 0, err
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return "", 0, err
+		}
+
+		host = string(buf[:n])
+	default:
+		socksReply(conn, socksAddressNotSupported)
+		return "", 0, fmt.Errorf("unsupported SOCKS address type %v", buf[3])
+	}
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", 0, err
+	}
+
+	return host, int(binary.BigEndian.Uint16(buf[:2])), nil
+}
+
+// socksReply sends a reply to a SOCKS5 request (the bound address is always reported as 0.0.0.0:0)
+func socksReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// proxyAllowList checks targets against allowed networks
+type proxyAllowList []*net.IPNet
+
+func (a proxyAllowList) allowed(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		// Hostnames are resolved in the webspace, so they can only be allowed if everything is
+		for _, n := range a {
+			if ones, _ := n.Mask.Size(); ones == 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, n := range a {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// webspaceNetworks gets the networks of a webspace's interfaces (and loopback)
+func webspaceNetworks(opts proxyOptions, token string) (proxyAllowList, error) {
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	state, _, err := client.StateApi.GetState(ctx, opts.User)
+	if err != nil {
+		return nil, util.APIError(err)
+	}
+
+	cidrs := []string{"127.0.0.0/8", "::1/128"}
+	for name, iface := range state.NetworkInterfaces {
+		if name == "lo" {
+			continue
+		}
+
+		for _, addr := range iface.Addresses {
+			cidrs = append(cidrs, addr.Address+"/"+addr.Netmask)
+		}
+	}
+
+	return parseAllowList(cidrs)
+}
+
+func parseAllowList(cidrs []string) (proxyAllowList, error) {
+	var list proxyAllowList
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", c, err)
+		}
+
+		list = append(list, n)
+	}
+
+	return list, nil
+}
+
+func proxyRun(opts proxyOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	var allow proxyAllowList
+	if len(opts.Allow) == 0 {
+		if allow, err = webspaceNetworks(opts, token); err != nil {
+			return fmt.Errorf("failed to get webspace networks: %w", err)
+		}
+	} else if allow, err = parseAllowList(opts.Allow); err != nil {
+		return err
+	}
+
+	nets := make([]string, len(allow))
+	for i, n := range allow {
+		nets[i] = n.String()
+	}
+
+	l, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	log.Printf("SOCKS5 proxy listening on %v (allowed targets: %v)", l.Addr(), strings.Join(nets, ", "))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		closed bool
+		active = map[net.Conn]struct{}{}
+	)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		<-sigs
+		log.Print("Shutting down")
+
+		mu.Lock()
+		defer mu.Unlock()
+		closed = true
+		l.Close()
+		for conn := range active {
+			conn.Close()
+		}
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			mu.Lock()
+			wasClosed := closed
+			mu.Unlock()
+			if wasClosed {
+				break
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		mu.Lock()
+		active[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(active, conn)
+				mu.Unlock()
+			}()
+
+			proxyConn(c, token, opts.User, allow, conn)
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// proxyConn handles a single SOCKS5 connection
+func proxyConn(c *config.Config, token, user string, allow proxyAllowList, conn net.Conn) {
+	defer conn.Close()
+
+	host, port, err := socksHandshake(conn)
+	if err != nil {
+		util.Debugf("SOCKS handshake with %v failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+
+	if !allow.allowed(host) {
+		util.Debugf("%v -> %v: not allowed", conn.RemoteAddr(), target)
+		socksReply(conn, socksNotAllowed)
+		return
+	}
+
+	s, err := webspaceDial(c, token, user, host, port)
+	if err != nil {
+		log.Printf("Failed to connect to %v from webspace: %v", target, err)
+		socksReply(conn, socksGeneralFailure)
+		return
+	}
+
+	// The relay doesn't report whether the connection succeeded, so we always report success
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		s.Close()
+		return
+	}
+
+	util.Debugf("%v -> %v: connected", conn.RemoteAddr(), target)
+	relay(conn, s)
+	util.Debugf("%v -> %v: closed", conn.RemoteAddr(), target)
+}
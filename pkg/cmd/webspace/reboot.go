@@ -2,67 +2,76 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type rebootOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User string
+	Wait waitOptions
 }
 
 // NewCmdReboot creates a new webspace reboot command
 func NewCmdReboot(f *util.CmdFactory) *cobra.Command {
 	opts := rebootOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
 		Use:     "reboot",
 		Aliases: []string{"restart"},
 		Short:   "Reboot webspace",
+		Long: heredoc.Doc(`
+			Reboot a webspace.
+		`) + waitHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return rebootRun(opts)
 		},
 	}
 
 	util.AddOptUser(cmd, &opts.User)
+	addOptWait(cmd, &opts.Wait, true)
 
 	return cmd
 }
 
 func rebootRun(opts rebootOptions) error {
-	c, err := opts.Config()
+	probes, err := parseProbes(opts.Wait.For)
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	await, _, t := util.SimpleProgress("Rebooting webspace", 5*time.Second)
-	defer await()
-
 	_, err = client.StateApi.Reboot(ctx, opts.User)
 	t.MarkAsDone()
+	await()
 	if err != nil {
 		return util.APIError(err)
 	}
 
-	return nil
+	if !opts.Wait.enabled() {
+		return nil
+	}
+
+	running := true
+	return waitForWebspace(client, token, opts.User, &running, opts.Wait, probes)
 }
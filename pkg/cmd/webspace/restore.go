@@ -0,0 +1,210 @@
+package webspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// restoreExtractScript checks the archive $1 has the SHA-256 hash $2 and extracts it to /
+const restoreExtractScript = `
+f=$1
+command -v zstd >/dev/null 2>&1 || { echo "ERR zstd isn't installed in the webspace"; exit 1; }
+[ "$(sha256sum "$f" | cut -d' ' -f1)" = "$2" ] || { rm -f "$f"; echo "ERR uploaded archive is corrupt"; exit 1; }
+echo OK
+zstd -dc "$f" | tar -xpf - -C / 2>&1
+s=$?; rm -f "$f"; exit $s
+`
+
+type restoreOptions struct {
+	Config          func() (*config.Config, error)
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User      string
+	File      string
+	DryRun    bool
+	Prune     bool
+	NoConfirm bool
+	Retries   int
+	Timeout   time.Duration
+}
+
+// NewCmdRestore creates a new webspace restore command
+func NewCmdRestore(f *util.CmdFactory) *cobra.Command {
+	opts := restoreOptions{
+		Config:          f.Config,
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "restore -f <file.tar.zst>",
+		Short: "Restore webspace backup",
+		Long: heredoc.Doc(`
+			Restore a backup made with "netsoc webspace backup" (which can be
+			of another user's webspace). The config, domains and port forwards
+			in the backup's metadata are re-applied (creating the webspace if
+			it doesn't exist) and the archive is then uploaded and extracted
+			over the webspace's files. Files which aren't in the backup are
+			left alone.
+
+			The changes are printed and (when running interactively) confirmed
+			before they're made. With --dry-run, they're only printed.
+
+			The upload picks up where it left off if the connection drops (or
+			if restore is run again after failing). The webspace needs tar,
+			zstd, sha256sum and stty.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return restoreRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	cmd.Flags().StringVarP(&opts.File, "filename", "f", "", "backup archive `file`")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only print the changes which would be made")
+	cmd.Flags().BoolVar(&opts.Prune, "prune", false, "remove domains and ports which aren't in the backup")
+	cmd.Flags().BoolVar(&opts.NoConfirm, "yes", false, "don't ask for confirmation")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 5, "`number` of times to retry a dropped transfer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait for the webspace to start")
+	cmd.MarkFlagRequired("filename")
+
+	return cmd
+}
+
+// backupManifest creates a manifest which restores the config, domains and port forwards in a backup's metadata
+func backupManifest(user string, meta backupMetadata) manifest {
+	m := exportManifest(liveWebspace{
+		Config:  meta.Config,
+		Domains: meta.Domains,
+		Ports:   meta.Ports,
+	})
+	m.User = user
+	m.Image = meta.Image
+	m.Running = nil
+
+	return m
+}
+
+func restoreRun(opts restoreOptions) error {
+	meta, err := readBackupMetadata(opts.File)
+	if err != nil {
+		return err
+	}
+
+	sum, err := fileSHA256(opts.File)
+	if err != nil {
+		return err
+	}
+	if sum != meta.SHA256 {
+		return errors.New("archive doesn't match its metadata (checksum mismatch)")
+	}
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	live, err := getLiveWebspace(ctx, client, opts.User)
+	if err != nil {
+		return fmt.Errorf("failed to get webspace: %w", err)
+	}
+	plan, err := planWebspace(client, token, backupManifest(opts.User, meta), live, opts.Prune)
+	if err != nil {
+		return err
+	}
+
+	printPlans(os.Stdout, []webspacePlan{plan})
+	fmt.Printf("  ~ files: %v (%v backup of %v from %v)\n", strings.Join(meta.Paths, ", "),
+		humanize.IBytes(uint64(meta.Size)), meta.User, meta.Created.Local().Format(util.TableDateFormat))
+	if opts.DryRun {
+		return nil
+	}
+
+	if !opts.NoConfirm && util.IsInteractive() {
+		restore, err := util.YesNo("Restore backup?", false)
+		if err != nil {
+			return err
+		}
+
+		if !restore {
+			return nil
+		}
+	}
+
+	for _, s := range plan.Steps {
+		if err := s.apply(ctx); err != nil {
+			return fmt.Errorf("failed to apply %v %v: %w", s.Op, s.Description, util.APIError(err))
+		}
+
+		util.Debugf("Applied %v %v", s.Op, s.Description)
+	}
+
+	if err := ensureRunning(client, token, opts.User, opts.Timeout); err != nil {
+		return err
+	}
+
+	f, err := os.Open(opts.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The name is derived from the archive so an interrupted upload can be continued by running restore again
+	remote := "/tmp/netsoc-restore." + meta.SHA256[:16]
+	await, t := util.TransferProgress(fmt.Sprintf("Uploading backup (%v)", humanize.IBytes(uint64(meta.Size))),
+		meta.Size)
+	if err := retryTransfer(opts.Retries, func() error {
+		return uploadFile(c, token, opts.User, f, meta.Size, remote, t)
+	}); err != nil {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to upload backup: %w", err)
+	}
+	t.MarkAsDone()
+	await()
+
+	s, err := util.StartExecStream(c, token, opts.User, restoreExtractScript, remote, meta.SHA256)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if _, err := readStatus(s); err != nil {
+		return err
+	}
+	out, code, err := s.Output()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("failed to extract archive in webspace (exit code %v): %v", code, strings.TrimSpace(out))
+	}
+
+	log.Printf("Restored backup (%v changes, %v)", len(plan.Steps), strings.Join(meta.Paths, ", "))
+	return nil
+}
@@ -2,67 +2,71 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type shutdownOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User string
+	Wait waitOptions
 }
 
 // NewCmdStop creates a new webspace shutdown command
 func NewCmdStop(f *util.CmdFactory) *cobra.Command {
 	opts := shutdownOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
 		Use:     "shutdown",
 		Aliases: []string{"stop"},
 		Short:   "Shut down webspace",
+		Long: heredoc.Doc(`
+			Shut down a webspace.
+		`) + waitHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return shutdownRun(opts)
 		},
 	}
 
 	util.AddOptUser(cmd, &opts.User)
+	addOptWait(cmd, &opts.Wait, false)
 
 	return cmd
 }
 
 func shutdownRun(opts shutdownOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	await, _, t := util.SimpleProgress("Shutting down webspace", 5*time.Second)
-	defer await()
-
 	_, err = client.StateApi.Shutdown(ctx, opts.User)
 	t.MarkAsDone()
+	await()
 	if err != nil {
 		return util.APIError(err)
 	}
 
-	return nil
+	if !opts.Wait.enabled() {
+		return nil
+	}
+
+	running := false
+	return waitForWebspace(client, token, opts.User, &running, opts.Wait, nil)
 }
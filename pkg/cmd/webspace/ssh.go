@@ -0,0 +1,356 @@
+package webspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/util"
+	iam "github.com/netsoc/iam/client"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+type sshOptions struct {
+	Config          func() (*config.Config, error)
+	ConfigPath      func() string
+	Token           func() (string, error)
+	IAMClient       func() (*iam.APIClient, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	User     string
+	Hostname string
+	Tunnel   bool
+}
+
+// sshTarget is where to connect to SSH in a webspace
+type sshTarget struct {
+	// Username is the user's account username (used to log in)
+	Username string
+	Hostname string
+	// Port is the external port forwarded to port 22 in the webspace (0 if there isn't one)
+	Port int
+}
+
+// getSSHTarget looks up the username and forwarded SSH port for a webspace
+func getSSHTarget(opts sshOptions) (sshTarget, error) {
+	var target sshTarget
+
+	c, err := opts.Config()
+	if err != nil {
+		return target, err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return target, err
+	}
+
+	target.Hostname = opts.Hostname
+	if target.Hostname == "" {
+		u, err := url.Parse(c.URLs.Webspaced)
+		if err != nil {
+			return target, fmt.Errorf("failed to parse webspaced URL: %w", err)
+		}
+
+		target.Hostname = u.Hostname()
+	}
+
+	iamClient, err := opts.IAMClient()
+	if err != nil {
+		return target, err
+	}
+	user, _, err := iamClient.UsersApi.GetUser(context.WithValue(context.Background(), iam.ContextAccessToken, token),
+		opts.User)
+	if err != nil {
+		return target, util.APIError(err)
+	}
+	target.Username = user.Username
+
+	if opts.Tunnel {
+		return target, nil
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return target, err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	ports, _, err := client.PortsApi.GetPorts(ctx, opts.User)
+	if err != nil {
+		return target, util.APIError(err)
+	}
+	for e, i := range ports {
+		if i != 22 {
+			continue
+		}
+
+		p, err := strconv.Atoi(e)
+		if err == nil && (target.Port == 0 || p < target.Port) {
+			target.Port = p
+		}
+	}
+	if target.Port == 0 {
+		return target, errors.New("webspace has no port forward for SSH (add one with `netsoc webspace ports add 22` " +
+			"or use --tunnel)")
+	}
+
+	return target, nil
+}
+
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@+=:,./-]+$`)
+
+// shellQuote quotes s for use in a shell command line (as used by ssh's ProxyCommand)
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// proxyCommand generates an SSH ProxyCommand which tunnels to a webspace using this executable. ssh expands %
+// tokens in ProxyCommand (even inside quotes), so any literal % is escaped.
+func proxyCommand(opts sshOptions) (string, error) {
+	c, err := opts.Config()
+	if err != nil {
+		return "", err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to find netsoc executable: %w", err)
+	}
+
+	args := []string{
+		exe,
+		"--config", opts.ConfigPath(),
+		"--profile", c.ProfileName,
+		"webspace", "ssh", "--proxy-command",
+		"--user", opts.User,
+	}
+	for i, a := range args {
+		args[i] = shellQuote(a)
+	}
+
+	return strings.ReplaceAll(strings.Join(args, " "), "%", "%%"), nil
+}
+
+type sshConfigOptions struct {
+	sshOptions
+
+	Alias string
+}
+
+// NewCmdSSHConfig creates a new webspace ssh-config command
+func NewCmdSSHConfig(f *util.CmdFactory) *cobra.Command {
+	opts := sshConfigOptions{
+		sshOptions: sshOptions{
+			Config:          f.Config,
+			ConfigPath:      f.ConfigPath,
+			Token:           f.Token,
+			IAMClient:       f.IAMClient,
+			WebspacedClient: f.WebspacedClient,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "ssh-config",
+		Short: "Generate SSH config for webspace",
+		Long: heredoc.Doc(`
+			Print an ssh_config(5) Host block for connecting to a webspace
+			over SSH, using the external port forwarded to port 22 and the
+			account's username. For example:
+
+			  netsoc webspace ssh-config >> ~/.ssh/config
+			  ssh webspace-<username>
+
+			With --tunnel, the connection is made over the exec websocket
+			instead (by running "netsoc webspace ssh --proxy-command"), so no
+			public port forward is needed.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sshConfigRun(opts)
+		},
+	}
+
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Alias, "alias", "", "`name` of the Host (defaults to webspace-<username>)")
+	cmd.Flags().StringVar(&opts.Hostname, "hostname", "", "`host` to connect to (defaults to the webspaced host)")
+	cmd.Flags().BoolVar(&opts.Tunnel, "tunnel", false, "connect over the exec websocket")
+
+	return cmd
+}
+
+func sshConfigRun(opts sshConfigOptions) error {
+	target, err := getSSHTarget(opts.sshOptions)
+	if err != nil {
+		return err
+	}
+
+	if opts.Alias == "" {
+		opts.Alias = "webspace-" + target.Username
+	}
+
+	fmt.Printf("Host %v\n", opts.Alias)
+	fmt.Printf("  HostName %v\n", target.Hostname)
+	fmt.Printf("  User %v\n", target.Username)
+	if opts.Tunnel {
+		cmd, err := proxyCommand(opts.sshOptions)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("  ProxyCommand %v\n", cmd)
+		// The host key is the webspace's, not the webspaced host's
+		fmt.Printf("  HostKeyAlias %v\n", opts.Alias)
+	} else {
+		fmt.Printf("  Port %v\n", target.Port)
+	}
+
+	return nil
+}
+
+type sshRunOptions struct {
+	sshOptions
+
+	ProxyCommand bool
+	Args         []string
+}
+
+// NewCmdSSH creates a new webspace ssh command
+func NewCmdSSH(f *util.CmdFactory) *cobra.Command {
+	opts := sshRunOptions{
+		sshOptions: sshOptions{
+			Config:          f.Config,
+			ConfigPath:      f.ConfigPath,
+			Token:           f.Token,
+			IAMClient:       f.IAMClient,
+			WebspacedClient: f.WebspacedClient,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "ssh [-- ssh-arg...]",
+		Short: "Connect to webspace over SSH",
+		Long: heredoc.Doc(`
+			Run the system ssh to connect to a webspace, using the external
+			port forwarded to port 22 and the account's username. Any extra
+			arguments (options or a command) are passed to ssh, e.g.:
+
+			  netsoc webspace ssh -- -L 8080:localhost:80
+			  netsoc webspace ssh -- uptime
+
+			With --tunnel, the connection is made over the exec websocket, so
+			no public port forward is needed (an SSH server must still be
+			running in the webspace).
+
+			With --proxy-command, stdin and stdout are connected to port 22 in
+			the webspace instead (for use as ssh's ProxyCommand, see
+			"netsoc webspace ssh-config --tunnel").
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Args = args
+			if opts.ProxyCommand {
+				if len(args) != 0 {
+					return errors.New("--proxy-command doesn't take any arguments")
+				}
+
+				return sshProxyRun(opts)
+			}
+
+			return sshRun(opts)
+		},
+	}
+
+	util.AddOptProfileUser(cmd, &opts.User)
+	cmd.Flags().StringVar(&opts.Hostname, "hostname", "", "`host` to connect to (defaults to the webspaced host)")
+	cmd.Flags().BoolVar(&opts.Tunnel, "tunnel", false, "connect over the exec websocket")
+	cmd.Flags().BoolVar(&opts.ProxyCommand, "proxy-command", false, "relay stdin and stdout to SSH in the webspace")
+
+	return cmd
+}
+
+func sshRun(opts sshRunOptions) error {
+	target, err := getSSHTarget(opts.sshOptions)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-l", target.Username}
+	if opts.Tunnel {
+		cmd, err := proxyCommand(opts.sshOptions)
+		if err != nil {
+			return err
+		}
+
+		args = append(args, "-o", "ProxyCommand="+cmd, "-o", "HostKeyAlias=webspace-"+target.Username)
+	} else {
+		args = append(args, "-p", strconv.Itoa(target.Port))
+	}
+	// ssh parses options after the destination, so extra arguments can include both options and a command
+	args = append(args, target.Hostname)
+	args = append(args, opts.Args...)
+
+	util.Debugf("Running ssh %v", strings.Join(args, " "))
+	ssh := exec.Command("ssh", args...)
+	ssh.Stdin = os.Stdin
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+
+	var exitErr *exec.ExitError
+	if err := ssh.Run(); errors.As(err, &exitErr) {
+		util.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return fmt.Errorf("failed to run ssh: %w", err)
+	}
+
+	return nil
+}
+
+func sshProxyRun(opts sshRunOptions) error {
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	s, err := webspaceDial(c, token, opts.User, "127.0.0.1", 22)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SSH in webspace: %w", err)
+	}
+	defer s.Close()
+
+	// ssh closes stdin once it's done, while the webspace side ends when the server closes the connection
+	var once sync.Once
+	done := make(chan struct{})
+	finish := func() {
+		once.Do(func() { close(done) })
+	}
+	go func() {
+		io.Copy(s, os.Stdin)
+		finish()
+	}()
+	go func() {
+		io.Copy(os.Stdout, s)
+		finish()
+	}()
+
+	<-done
+	return nil
+}
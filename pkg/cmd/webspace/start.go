@@ -2,67 +2,76 @@ package webspace
 
 import (
 	"context"
-	"errors"
 	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type startOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User string
+	Wait waitOptions
 }
 
 // NewCmdStart creates a new webspace start command
 func NewCmdStart(f *util.CmdFactory) *cobra.Command {
 	opts := startOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
 		Use:     "boot",
 		Aliases: []string{"start"},
 		Short:   "Boot webspace",
+		Long: heredoc.Doc(`
+			Boot a webspace.
+		`) + waitHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return startRun(opts)
 		},
 	}
 
 	util.AddOptUser(cmd, &opts.User)
+	addOptWait(cmd, &opts.Wait, true)
 
 	return cmd
 }
 
 func startRun(opts startOptions) error {
-	c, err := opts.Config()
+	probes, err := parseProbes(opts.Wait.For)
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
+	token, err := opts.Token()
+	if err != nil {
+		return err
 	}
 
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	await, _, t := util.SimpleProgress("Starting webspace", 5*time.Second)
-	defer await()
-
 	_, err = client.StateApi.Start(ctx, opts.User)
 	t.MarkAsDone()
+	await()
 	if err != nil {
 		return util.APIError(err)
 	}
 
-	return nil
+	if !opts.Wait.enabled() {
+		return nil
+	}
+
+	running := true
+	return waitForWebspace(client, token, opts.User, &running, opts.Wait, probes)
 }
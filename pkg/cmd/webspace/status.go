@@ -2,142 +2,225 @@ package webspace
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
-	"text/template"
 	"time"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 
-	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type statusOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
-	OutputFormat string
-	User         string
+	Output printer.Options
+	Watch  printer.WatchOptions
+	User   string
 }
 
 // NewCmdStatus creates a new webspace status command
 func NewCmdStatus(f *util.CmdFactory) *cobra.Command {
 	opts := statusOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
 		Use:     "status",
 		Aliases: []string{"state"},
 		Short:   "Get status",
+		Long: heredoc.Doc(`
+			Get the status of a webspace.
+
+			With --watch, the status is refreshed periodically and changes
+			in resource usage are highlighted.
+		`),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return statusRun(opts)
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.OutputFormat, "output", "o", "text", "output format `text|yaml|json|template=<Go template>`")
-	util.AddOptUser(cmd, &opts.User)
+	util.AddOptFormat(cmd, &opts.Output)
+	util.AddOptWatch(cmd, &opts.Watch)
+	util.AddOptProfileUser(cmd, &opts.User)
 
 	return cmd
 }
 
-func printState(state webspaced.State, outputType string) error {
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
+// ifRunning formats a value only if the webspace is running
+func ifRunning(state webspaced.State, f func() string) string {
+	if !state.Running {
+		return "-"
+	}
+
+	return f()
+}
+
+// usageDelta describes the change in some usage value between two states (for --watch)
+func usageDelta(get func(s webspaced.State) int64, format func(d int64) string) func(prev, cur interface{}) string {
+	return func(prev, cur interface{}) string {
+		p, c := prev.(webspaced.State), cur.(webspaced.State)
+		if !p.Running || !c.Running {
+			return ""
 		}
 
-		if err := tpl.Execute(os.Stdout, state); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
+		d := get(c) - get(p)
+		if d == 0 {
+			return ""
 		}
 
-		return nil
+		return format(d)
 	}
+}
 
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(state); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(state); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	case "text":
-		running := "no"
-		if state.Running {
-			running = "yes"
-		}
-		fmt.Printf("Running: %v\n", running)
+// signedBytes formats a change in a number of bytes
+func signedBytes(d int64) string {
+	if d < 0 {
+		return "-" + humanize.IBytes(uint64(-d))
+	}
 
-		if len(state.Usage.Disks) > 0 {
-			fmt.Println("Disks:")
-			for n, usage := range state.Usage.Disks {
-				fmt.Printf(" - %v: Used %v\n", n, humanize.IBytes(uint64(usage)))
-			}
-		}
+	return "+" + humanize.IBytes(uint64(d))
+}
 
-		if state.Running {
-			fmt.Printf("Uptime: %v\n", time.Duration(state.Uptime*float64(time.Second.Nanoseconds())))
-			fmt.Printf("CPU time: %v\n", time.Duration(state.Usage.Cpu))
-			fmt.Printf("Memory usage: %v\n", humanize.IBytes(uint64(state.Usage.Memory)))
-			fmt.Printf("Running processes: %v\n", state.Usage.Processes)
-
-			if len(state.NetworkInterfaces) > 0 {
-				fmt.Println("Network interfaces:")
-				for n, iface := range state.NetworkInterfaces {
-					fmt.Printf(" - %v (%v)\n", n, iface.Mac)
-					fmt.Printf("   Sent/received: %v/%v\n",
-						humanize.IBytes(uint64(iface.Counters.BytesSent)),
-						humanize.IBytes(uint64(iface.Counters.BytesReceived)),
-					)
-
-					for _, addr := range iface.Addresses {
-						t := "IPv4"
-						if addr.Family == "inet6" {
-							t = "IPv6"
-						}
-
-						fmt.Printf("   %v address: %v/%v\n", t, addr.Address, addr.Netmask)
+func init() {
+	var (
+		running = printer.Column{Header: "Running", Value: func(i interface{}) string {
+			return printer.YesNo(i.(webspaced.State).Running)
+		}}
+		uptime = printer.Column{Header: "Uptime", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			return ifRunning(s, func() string {
+				return time.Duration(s.Uptime * float64(time.Second)).Round(time.Second).String()
+			})
+		}}
+		cpu = printer.Column{Header: "CPU time", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			return ifRunning(s, func() string {
+				return time.Duration(s.Usage.Cpu).String()
+			})
+		}, Delta: usageDelta(func(s webspaced.State) int64 { return s.Usage.Cpu }, func(d int64) string {
+			return "+" + time.Duration(d).Round(time.Millisecond).String()
+		})}
+		memory = printer.Column{Header: "Memory", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			return ifRunning(s, func() string {
+				return humanize.IBytes(uint64(s.Usage.Memory))
+			})
+		}, Delta: usageDelta(func(s webspaced.State) int64 { return s.Usage.Memory }, signedBytes)}
+		processes = printer.Column{Header: "Processes", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			return ifRunning(s, func() string {
+				return fmt.Sprint(s.Usage.Processes)
+			})
+		}, Delta: usageDelta(func(s webspaced.State) int64 { return s.Usage.Processes }, func(d int64) string {
+			return fmt.Sprintf("%+d", d)
+		})}
+		disks = printer.Column{Header: "Disks", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			lines := make([]string, 0, len(s.Usage.Disks))
+			for n, usage := range s.Usage.Disks {
+				lines = append(lines, fmt.Sprintf("%v: %v", n, humanize.IBytes(uint64(usage))))
+			}
+			sort.Strings(lines)
+
+			return strings.Join(lines, "\n")
+		}}
+		network = printer.Column{Header: "Network interfaces", Value: func(i interface{}) string {
+			s := i.(webspaced.State)
+			names := make([]string, 0, len(s.NetworkInterfaces))
+			for n := range s.NetworkInterfaces {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+
+			var lines []string
+			for _, n := range names {
+				iface := s.NetworkInterfaces[n]
+				lines = append(lines, fmt.Sprintf("%v (%v), sent/received: %v/%v", n, iface.Mac,
+					humanize.IBytes(uint64(iface.Counters.BytesSent)),
+					humanize.IBytes(uint64(iface.Counters.BytesReceived)),
+				))
+
+				for _, addr := range iface.Addresses {
+					t := "IPv4"
+					if addr.Family == "inet6" {
+						t = "IPv6"
 					}
+
+					lines = append(lines, fmt.Sprintf("  %v: %v/%v", t, addr.Address, addr.Netmask))
 				}
 			}
-		}
-	default:
-		return fmt.Errorf(`unknown output format "%v"`, outputType)
-	}
 
-	return nil
+			return strings.Join(lines, "\n")
+		}, Delta: func(prev, cur interface{}) string {
+			p, c := prev.(webspaced.State), cur.(webspaced.State)
+			names := make([]string, 0, len(c.NetworkInterfaces))
+			for n := range c.NetworkInterfaces {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+
+			var lines []string
+			for _, n := range names {
+				old, ok := p.NetworkInterfaces[n]
+				if !ok {
+					continue
+				}
+
+				counters := c.NetworkInterfaces[n].Counters
+				sent := counters.BytesSent - old.Counters.BytesSent
+				received := counters.BytesReceived - old.Counters.BytesReceived
+				packetsSent := counters.PacketsSent - old.Counters.PacketsSent
+				packetsReceived := counters.PacketsReceived - old.Counters.PacketsReceived
+				if sent != 0 || received != 0 || packetsSent != 0 || packetsReceived != 0 {
+					lines = append(lines, fmt.Sprintf("%v sent/received: %v/%v (%+d/%+d packets)", n,
+						signedBytes(sent), signedBytes(received), packetsSent, packetsReceived))
+				}
+			}
+
+			return strings.Join(lines, "\n")
+		}}
+	)
+
+	printer.Register(webspaced.State{}, printer.TableSpec{
+		Columns: []printer.Column{running, uptime, cpu, memory, processes, disks, network},
+	})
 }
 
 func statusRun(opts statusOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	fetch := func() (interface{}, error) {
+		state, _, err := client.StateApi.GetState(ctx, opts.User)
+		if err != nil {
+			return nil, util.APIError(err)
+		}
 
-	state, _, err := client.StateApi.GetState(ctx, opts.User)
+		return state, nil
+	}
+	if opts.Watch.Enabled {
+		return printer.Watch(fetch, opts.Output, opts.Watch)
+	}
+
+	state, err := fetch()
 	if err != nil {
-		return util.APIError(err)
+		return err
 	}
 
-	return printState(state, opts.OutputFormat)
+	return printer.Print(state, opts.Output)
 }
@@ -2,18 +2,16 @@ package webspace
 
 import (
 	"context"
-	"errors"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 
-	"github.com/netsoc/cli/pkg/config"
 	"github.com/netsoc/cli/pkg/util"
 	webspaced "github.com/netsoc/webspaced/client"
 )
 
 type syncOptions struct {
-	Config          func() (*config.Config, error)
+	Token           func() (string, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 
 	User string
@@ -22,7 +20,7 @@ type syncOptions struct {
 // NewCmdSync creates a new webspace sync command
 func NewCmdSync(f *util.CmdFactory) *cobra.Command {
 	opts := syncOptions{
-		Config:          f.Config,
+		Token:           f.Token,
 		WebspacedClient: f.WebspacedClient,
 	}
 	cmd := &cobra.Command{
@@ -43,20 +41,16 @@ func NewCmdSync(f *util.CmdFactory) *cobra.Command {
 }
 
 func syncRun(opts syncOptions) error {
-	c, err := opts.Config()
+	token, err := opts.Token()
 	if err != nil {
 		return err
 	}
 
-	if c.Token == "" {
-		return errors.New("not logged in")
-	}
-
 	client, err := opts.WebspacedClient()
 	if err != nil {
 		return err
 	}
-	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, c.Token)
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
 
 	_, err = client.StateApi.Sync(ctx, opts.User)
 	if err != nil {
@@ -0,0 +1,586 @@
+package webspace
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// syncIgnoreFile is read from the root of the local directory for exclude patterns
+const syncIgnoreFile = ".netsocignore"
+
+type syncDirOptions struct {
+	Config func() (*config.Config, error)
+	Token  func() (string, error)
+
+	User        string
+	Output      printer.Options
+	Source      string
+	Destination string
+	Delete      bool
+	Excludes    []string
+	DryRun      bool
+	// PrintResult is set if the result should be printed even after a successful (interactive) sync
+	PrintResult bool
+}
+
+// NewCmdSyncDir creates a new webspace sync-dir command
+func NewCmdSyncDir(f *util.CmdFactory) *cobra.Command {
+	opts := syncDirOptions{
+		Config: f.Config,
+		Token:  f.Token,
+	}
+	cmd := &cobra.Command{
+		Use:   "sync-dir <local directory> :<webspace directory>",
+		Short: "Synchronize a directory to webspace",
+		Long: heredoc.Doc(`
+			Synchronize a local directory to a webspace (e.g. to deploy a
+			static site). Files are compared by their SHA-256 hash and only
+			new or changed files are uploaded. With --delete, files in the
+			webspace which don't exist locally are removed.
+
+			Exclude patterns (--exclude, or one per line in a .netsocignore
+			file in the local directory) are matched against file names, or
+			against paths relative to the directory if they contain a "/".
+			Patterns ending in "/" only match directories. Excluded files are
+			never uploaded or deleted.
+
+			Use --dry-run to see what would change. The changes are printed
+			with --output or when not running interactively.
+
+			The webspace needs find, sha256sum, tar, head and stty.
+		`),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Source = args[0]
+
+			var ok bool
+			if opts.Destination, ok = remotePath(args[1]); !ok || opts.Destination == "" {
+				return errors.New(`destination must be a webspace path (prefixed with ":")`)
+			}
+
+			opts.PrintResult = cmd.Flags().Changed("output") || !util.IsInteractive()
+			return syncDirRun(opts)
+		},
+	}
+
+	util.AddOptUser(cmd, &opts.User)
+	util.AddOptFormat(cmd, &opts.Output)
+	cmd.Flags().BoolVar(&opts.Delete, "delete", false, "delete files in webspace which don't exist locally")
+	cmd.Flags().StringArrayVar(&opts.Excludes, "exclude", []string{}, "exclude files matching `pattern`")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "only show what would change")
+
+	return cmd
+}
+
+// syncChange is a change made to a file in the webspace
+type syncChange struct {
+	Action string `json:"action" yaml:"action"`
+	Path   string `json:"path" yaml:"path"`
+	Size   int64  `json:"size" yaml:"size"`
+}
+
+// syncResult summarizes a sync
+type syncResult struct {
+	DryRun    bool         `json:"dry_run" yaml:"dry_run"`
+	Changes   []syncChange `json:"changes" yaml:"changes"`
+	Unchanged int          `json:"unchanged" yaml:"unchanged"`
+	// Transferred is the size of the uploaded archive in bytes
+	Transferred int64 `json:"transferred" yaml:"transferred"`
+}
+
+func (r syncResult) count(action string) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+
+	return n
+}
+
+func init() {
+	printer.Register(syncResult{}, printer.TableSpec{
+		Rows: func(data interface{}) []interface{} {
+			changes := data.(syncResult).Changes
+			rows := make([]interface{}, len(changes))
+			for i, c := range changes {
+				rows[i] = c
+			}
+
+			return rows
+		},
+		Columns: []printer.Column{
+			{Header: "Action", Value: func(i interface{}) string {
+				return i.(syncChange).Action
+			}},
+			{Header: "Path", Value: func(i interface{}) string {
+				return i.(syncChange).Path
+			}},
+			{Header: "Size", Value: func(i interface{}) string {
+				c := i.(syncChange)
+				if c.Action == "delete" || strings.HasSuffix(c.Path, "/") {
+					return "-"
+				}
+
+				return humanize.IBytes(uint64(c.Size))
+			}},
+		},
+	})
+}
+
+// syncExcludes matches paths against exclude patterns
+type syncExcludes []string
+
+// readSyncExcludes reads patterns from an ignore file (blank lines and lines starting with "#" are skipped)
+func readSyncExcludes(file string) (syncExcludes, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns syncExcludes
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l != "" && !strings.HasPrefix(l, "#") {
+			patterns = append(patterns, l)
+		}
+	}
+
+	return patterns, s.Err()
+}
+
+// match checks if a path (relative to the root, with "/" separators) is excluded. Patterns are checked against each
+// parent of the path too, since excluding a directory excludes its contents.
+func (e syncExcludes) match(rel string, dir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		p := strings.Join(parts[:i+1], "/")
+		isDir := dir || i < len(parts)-1
+
+		for _, pattern := range e {
+			if strings.HasSuffix(pattern, "/") {
+				if !isDir {
+					continue
+				}
+				pattern = strings.TrimSuffix(pattern, "/")
+			}
+
+			subject := parts[i]
+			if strings.Contains(pattern, "/") {
+				subject, pattern = p, strings.TrimPrefix(pattern, "/")
+			}
+			if ok, _ := path.Match(pattern, subject); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// syncEntry is a file, directory or symlink. Hash is the SHA-256 of a file's contents or a symlink's target.
+type syncEntry struct {
+	Type byte
+	Hash string
+	Size int64
+}
+
+// localSyncEntries lists and hashes the files in a local directory
+func localSyncEntries(root string, excludes syncExcludes) (map[string]syncEntry, error) {
+	entries := map[string]syncEntry{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if excludes.match(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case info.IsDir():
+			entries[rel] = syncEntry{Type: 'd'}
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+
+			entries[rel] = syncEntry{Type: 'l', Hash: target}
+		case info.Mode().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err != nil {
+				return err
+			}
+
+			entries[rel] = syncEntry{Type: 'f', Hash: hex.EncodeToString(h.Sum(nil)), Size: info.Size()}
+		default:
+			log.Printf("Skipping %v (unsupported file type)", rel)
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// syncListScript lists the contents of $1 as tab-separated lines of type, hash (or symlink target) and path
+const syncListScript = `
+dir=$1
+[ -d "$dir" ] || { echo OK; exit 0; }
+cd -- "$dir" || { echo "ERR $dir: permission denied"; exit 1; }
+echo OK
+find . ! -path . -type d 2>/dev/null | while IFS= read -r p; do printf 'd\t\t%s\n' "$p"; done
+find . -type l 2>/dev/null | while IFS= read -r p; do printf 'l\t%s\t%s\n' "$(readlink "$p")" "$p"; done
+find . -type f -exec sha256sum {} + 2>/dev/null | while IFS= read -r l; do printf 'f\t%s\t%s\n' "${l%%  *}" "${l#*  }"; done
+`
+
+// remoteSyncEntries lists and hashes the files in a webspace directory
+func remoteSyncEntries(c *config.Config, token, user, dir string, excludes syncExcludes) (map[string]syncEntry, error) {
+	s, err := util.StartExecStream(c, token, user, syncListScript, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if _, err := readStatus(s); err != nil {
+		return nil, err
+	}
+
+	entries := map[string]syncEntry{}
+	for {
+		line, err := s.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file list: %w", err)
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 || len(fields[0]) != 1 {
+			util.Debugf("Ignoring unexpected line in file list: %q", line)
+			continue
+		}
+
+		rel := strings.TrimPrefix(fields[2], "./")
+		if excludes.match(rel, fields[0] == "d") {
+			continue
+		}
+
+		entries[rel] = syncEntry{Type: fields[0][0], Hash: fields[1]}
+	}
+
+	code, err := s.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("failed to list files in webspace (exit code %v)", code)
+	}
+
+	return entries, nil
+}
+
+// planSync works out the changes needed to make remote match local
+func planSync(local, remote map[string]syncEntry, del bool) syncResult {
+	var result syncResult
+
+	paths := make([]string, 0, len(local))
+	for p := range local {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		l := local[p]
+		r, exists := remote[p]
+
+		name := p
+		if l.Type == 'd' {
+			name += "/"
+		}
+		switch {
+		case !exists:
+			result.Changes = append(result.Changes, syncChange{Action: "add", Path: name, Size: l.Size})
+		case l.Type != r.Type || l.Hash != r.Hash:
+			result.Changes = append(result.Changes, syncChange{Action: "update", Path: name, Size: l.Size})
+		case l.Type != 'd':
+			result.Unchanged++
+		}
+	}
+
+	if !del {
+		return result
+	}
+
+	paths = paths[:0]
+	for p := range remote {
+		if _, ok := local[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var deleted []string
+	for _, p := range paths {
+		// Deleting a directory deletes its contents
+		parent := false
+		for _, d := range deleted {
+			if strings.HasPrefix(p, d+"/") {
+				parent = true
+				break
+			}
+		}
+		if parent {
+			continue
+		}
+
+		deleted = append(deleted, p)
+		name := p
+		if remote[p].Type == 'd' {
+			name += "/"
+		}
+		result.Changes = append(result.Changes, syncChange{Action: "delete", Path: name})
+	}
+
+	return result
+}
+
+// syncDeleteScript deletes paths (the remaining arguments) relative to $1
+const syncDeleteScript = `
+dir=$1; shift
+cd -- "$dir" || exit 1
+rm -rf -- "$@" 2>&1
+`
+
+// syncUploadScript extracts a tar archive of size $2 into $1 (creating it if necessary)
+const syncUploadScript = `
+dir=$1; size=$2
+mkdir -p -- "$dir" 2>/dev/null && cd -- "$dir" || { echo "ERR failed to create $dir"; exit 1; }
+echo OK
+head -c "$size" | tar -xf - 2>&1
+`
+
+func runSyncScript(c *config.Config, token, user, script string, args ...string) error {
+	s, err := util.StartExecStream(c, token, user, script, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	out, code, err := s.Output()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("exit code %v: %v", code, strings.TrimSpace(out))
+	}
+
+	return nil
+}
+
+func syncDirRun(opts syncDirOptions) error {
+	if info, err := os.Stat(opts.Source); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", opts.Source)
+	}
+
+	excludes, err := readSyncExcludes(filepath.Join(opts.Source, syncIgnoreFile))
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %w", syncIgnoreFile, err)
+	}
+	excludes = append(append(excludes, "/"+syncIgnoreFile), opts.Excludes...)
+
+	c, err := opts.Config()
+	if err != nil {
+		return err
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	local, err := localSyncEntries(opts.Source, excludes)
+	if err != nil {
+		return fmt.Errorf("failed to list local files: %w", err)
+	}
+	remote, err := remoteSyncEntries(c, token, opts.User, opts.Destination, excludes)
+	if err != nil {
+		return err
+	}
+
+	result := planSync(local, remote, opts.Delete)
+	result.DryRun = opts.DryRun
+
+	var upload, remove []string
+	for _, change := range result.Changes {
+		p := strings.TrimSuffix(change.Path, "/")
+		switch change.Action {
+		case "add":
+			upload = append(upload, p)
+		case "update":
+			upload = append(upload, p)
+
+			// Changing the type of a path (e.g. directory to file) requires deleting it first
+			if local[p].Type != remote[p].Type {
+				remove = append(remove, p)
+			}
+		case "delete":
+			remove = append(remove, p)
+		}
+	}
+
+	if !opts.DryRun {
+		if err := syncDirApply(c, token, opts, upload, remove, &result); err != nil {
+			return err
+		}
+	}
+
+	verb := "Synced"
+	if opts.DryRun {
+		verb = "Would sync"
+	}
+	log.Printf("%v: %v added, %v updated, %v deleted, %v unchanged (%v transferred)", verb, result.count("add"),
+		result.count("update"), result.count("delete"), result.Unchanged, humanize.IBytes(uint64(result.Transferred)))
+
+	if opts.DryRun || opts.PrintResult {
+		return printer.Print(result, opts.Output)
+	}
+	return nil
+}
+
+func syncDirApply(c *config.Config, token string, opts syncDirOptions, upload, remove []string, result *syncResult) error {
+	// Delete in batches to avoid hitting argument length limits
+	for len(remove) > 0 {
+		n := len(remove)
+		if n > 256 {
+			n = 256
+		}
+
+		args := []string{opts.Destination}
+		for _, p := range remove[:n] {
+			args = append(args, "./"+p)
+		}
+		if err := runSyncScript(c, token, opts.User, syncDeleteScript, args...); err != nil {
+			return fmt.Errorf("failed to delete files in webspace: %w", err)
+		}
+
+		remove = remove[n:]
+	}
+
+	if len(upload) == 0 {
+		return nil
+	}
+
+	archive, err := ioutil.TempFile("", "netsoc-sync-*.tar")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	tw := tar.NewWriter(archive)
+	for _, p := range upload {
+		lp := filepath.Join(opts.Source, filepath.FromSlash(p))
+		info, err := os.Lstat(lp)
+		if err != nil {
+			return err
+		}
+
+		if err := addTarEntry(tw, lp, p, info); err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	s, err := util.StartExecStream(c, token, opts.User, syncUploadScript, opts.Destination,
+		strconv.FormatInt(size, 10))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if _, err := readStatus(s); err != nil {
+		return err
+	}
+
+	await, t := util.TransferProgress(fmt.Sprintf("Uploading %v files", len(upload)), size)
+	if _, err := io.Copy(s, io.TeeReader(archive, util.ProgressWriter{Tracker: t})); err != nil {
+		t.MarkAsErrored()
+		await()
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+
+	out, code, err := s.Output()
+	if err == nil && code != 0 {
+		err = fmt.Errorf("failed to extract archive in webspace (exit code %v): %v", code, strings.TrimSpace(out))
+	}
+	if err != nil {
+		t.MarkAsErrored()
+		await()
+		return err
+	}
+
+	t.MarkAsDone()
+	await()
+	result.Transferred = size
+	return nil
+}
@@ -0,0 +1,203 @@
+package webspace
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/netsoc/cli/pkg/util"
+)
+
+// builtinTemplates are the templates shipped with the CLI
+//
+//go:embed templates/*.yaml
+var builtinTemplates embed.FS
+
+// templateSourceBuiltin is the source of templates embedded in the CLI
+const templateSourceBuiltin = "built-in"
+
+// webspaceTemplate is a starter stack for a webspace
+type webspaceTemplate struct {
+	// Name defaults to the name of the file (without extension)
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Image       string `yaml:"image" json:"image"`
+	HTTPPort    int32  `yaml:"httpPort,omitempty" json:"httpPort,omitempty"`
+	// Ports are webspace ports which should be forwarded (from random external ports)
+	Ports     []int32         `yaml:"ports,omitempty" json:"ports,omitempty"`
+	Provision provisionConfig `yaml:"provision,omitempty" json:"provision"`
+	Notes     string          `yaml:"notes,omitempty" json:"notes,omitempty"`
+
+	// Source is where the template was loaded from (built-in or a file path)
+	Source string `yaml:"-" json:"source"`
+}
+
+// templatesHelp describes where templates come from
+const templatesHelp = `
+Built-in templates can be overridden (and new ones added) by placing YAML
+files in the templates directory (~/.config/netsoc/templates), e.g.:
+
+  # minecraft.yaml
+  description: Minecraft server
+  image: debian/11
+  ports: [25565]
+  provision:
+    packages: [openjdk-17-jre-headless]
+    commands:
+      - ...
+  notes: |
+    Connect to the server on the port forwarded to 25565.
+
+A template's httpPort sets the webspace's HTTP port config option, ports are
+forwarded from random external ports, provision is a provisioning config (see
+"netsoc webspace provision --help") and notes are shown once the webspace is
+ready.
+`
+
+// templatesDir returns the directory user templates are loaded from (~/.config/netsoc/templates on all platforms, so
+// templates can be shared the same way everywhere)
+func templatesDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "netsoc", "templates"), nil
+}
+
+// parseTemplate parses a template file
+func parseTemplate(name string, data []byte, source string) (webspaceTemplate, error) {
+	var t webspaceTemplate
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return t, fmt.Errorf("failed to parse template %v (%v): %w", name, source, err)
+	}
+
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if t.Image == "" {
+		return t, fmt.Errorf("template %v (%v) has no image", t.Name, source)
+	}
+	for _, p := range t.Ports {
+		if p < 1 || p > 65535 {
+			return t, fmt.Errorf("template %v (%v) has invalid port %v", t.Name, source, p)
+		}
+	}
+	if _, err := t.Provision.steps(); err != nil {
+		return t, fmt.Errorf("template %v (%v): %w", t.Name, source, err)
+	}
+	t.Source = source
+
+	return t, nil
+}
+
+// loadTemplates loads the built-in templates and those in the user's templates directory (which override built-in
+// templates with the same name)
+func loadTemplates() ([]webspaceTemplate, error) {
+	templates := map[string]webspaceTemplate{}
+
+	builtin, err := fs.Glob(builtinTemplates, "templates/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range builtin {
+		data, err := builtinTemplates.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := parseTemplate(path.Base(p), data, templateSourceBuiltin)
+		if err != nil {
+			return nil, err
+		}
+		templates[t.Name] = t
+	}
+
+	dir, err := templatesDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read templates directory: %w", err)
+	}
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		p := filepath.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := parseTemplate(f.Name(), data, p)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := templates[t.Name]; ok && existing.Source != templateSourceBuiltin {
+			return nil, fmt.Errorf("template %v is defined in both %v and %v", t.Name, existing.Source, p)
+		}
+		templates[t.Name] = t
+	}
+
+	list := make([]webspaceTemplate, 0, len(templates))
+	for _, t := range templates {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+// findTemplate loads the template with the given name
+func findTemplate(name string) (webspaceTemplate, error) {
+	templates, err := loadTemplates()
+	if err != nil {
+		return webspaceTemplate{}, err
+	}
+
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+		names[i] = t.Name
+	}
+
+	return webspaceTemplate{}, fmt.Errorf("unknown template %v (available: %v)", name, strings.Join(names, ", "))
+}
+
+// NewCmdTemplates creates a new webspace templates command
+func NewCmdTemplates(f *util.CmdFactory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "templates",
+		Short: "Manage webspace templates",
+		Long: heredoc.Doc(`
+			Templates are starter stacks for webspaces (e.g. WordPress or a
+			Node.js app), made up of an image, provisioning steps, the HTTP
+			port, suggested port forwards and post-install notes. Create a
+			webspace from a template with "netsoc webspace init --template".
+		`) + templatesHelp,
+	}
+
+	cmd.AddCommand(NewCmdTemplatesList(f), NewCmdTemplatesShow(f))
+
+	return cmd
+}
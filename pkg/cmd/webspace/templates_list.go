@@ -0,0 +1,70 @@
+package webspace
+
+import (
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type templatesListOptions struct {
+	Output printer.Options
+}
+
+// NewCmdTemplatesList creates a new webspace templates list command
+func NewCmdTemplatesList(f *util.CmdFactory) *cobra.Command {
+	opts := templatesListOptions{}
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List webspace templates",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return templatesListRun(opts)
+		},
+	}
+
+	util.AddOptFormat(cmd, &opts.Output)
+
+	return cmd
+}
+
+func init() {
+	var (
+		name = printer.Column{Header: "Name", Value: func(i interface{}) string {
+			return i.(webspaceTemplate).Name
+		}}
+		image = printer.Column{Header: "Image", Value: func(i interface{}) string {
+			return i.(webspaceTemplate).Image
+		}}
+		httpPort = printer.Column{Header: "HTTP port", Value: func(i interface{}) string {
+			if p := i.(webspaceTemplate).HTTPPort; p != 0 {
+				return strconv.Itoa(int(p))
+			}
+
+			return ""
+		}}
+		description = printer.Column{Header: "Description", Value: func(i interface{}) string {
+			return i.(webspaceTemplate).Description
+		}}
+		source = printer.Column{Header: "Source", Value: func(i interface{}) string {
+			return i.(webspaceTemplate).Source
+		}}
+	)
+
+	printer.Register(webspaceTemplate{}, printer.TableSpec{
+		Columns: []printer.Column{name, description, image},
+		Wide:    []printer.Column{name, description, image, httpPort, source},
+	})
+}
+
+func templatesListRun(opts templatesListOptions) error {
+	templates, err := loadTemplates()
+	if err != nil {
+		return err
+	}
+
+	return printer.Print(templates, opts.Output)
+}
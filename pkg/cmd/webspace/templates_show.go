@@ -0,0 +1,40 @@
+package webspace
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+)
+
+type templatesShowOptions struct {
+	Output printer.Options
+	Name   string
+}
+
+// NewCmdTemplatesShow creates a new webspace templates show command
+func NewCmdTemplatesShow(f *util.CmdFactory) *cobra.Command {
+	opts := templatesShowOptions{}
+	cmd := &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show webspace template",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Name = args[0]
+			return templatesShowRun(opts)
+		},
+	}
+
+	printer.AddFlags(cmd, &opts.Output, "yaml")
+
+	return cmd
+}
+
+func templatesShowRun(opts templatesShowOptions) error {
+	t, err := findTemplate(opts.Name)
+	if err != nil {
+		return err
+	}
+
+	return printer.Print(t, opts.Output)
+}
@@ -0,0 +1,383 @@
+package webspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/containerd/console"
+	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// topHistory is the maximum number of samples kept for each graph
+const topHistory = 512
+
+type topOptions struct {
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	Users    []string
+	Interval time.Duration
+}
+
+// NewCmdTop creates a new webspace top command
+func NewCmdTop(f *util.CmdFactory) *cobra.Command {
+	opts := topOptions{
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Monitor resource usage",
+		Long: heredoc.Doc(`
+			Show live graphs of CPU, memory, process count, disk usage and
+			network throughput. Admins can pass -u multiple times to monitor
+			several webspaces at once.
+
+			Press q (or Ctrl+C) to quit.
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return topRun(opts)
+		},
+	}
+
+	util.AddOptProfileUsers(cmd, &opts.Users)
+	cmd.Flags().DurationVar(&opts.Interval, "interval", 2*time.Second, "`interval` between updates")
+
+	return cmd
+}
+
+// topMetric is a graphed value
+type topMetric struct {
+	Label   string
+	Value   string
+	History []float64
+	// Max is the graph's fixed maximum (the maximum of the history is used if 0)
+	Max float64
+}
+
+// topWebspace tracks the usage history of a webspace
+type topWebspace struct {
+	User string
+
+	State   *webspaced.State
+	Err     error
+	Sampled time.Time
+
+	metrics map[string]*topMetric
+	// polling is set while a request for the webspace's state is in flight
+	polling bool
+}
+
+// topSample is the result of polling a webspace
+type topSample struct {
+	Index int
+	State webspaced.State
+	Err   error
+	At    time.Time
+}
+
+func (w *topWebspace) metric(key, label string) *topMetric {
+	m, ok := w.metrics[key]
+	if !ok {
+		m = &topMetric{Label: label, Value: "-"}
+		w.metrics[key] = m
+	}
+
+	return m
+}
+
+func (m *topMetric) push(v float64, value string) {
+	m.Value = value
+	m.History = append(m.History, v)
+	if len(m.History) > topHistory {
+		m.History = m.History[len(m.History)-topHistory:]
+	}
+}
+
+// update records a new sample, deriving rates from the previous one
+func (w *topWebspace) update(s topSample) {
+	w.polling = false
+	if s.At.Before(w.Sampled) {
+		// Rates can only be derived from samples in order
+		return
+	}
+	if s.Err != nil {
+		w.Err = s.Err
+		return
+	}
+	w.Err = nil
+
+	prev, prevAt := w.State, w.Sampled
+	cur := s.State
+	w.State, w.Sampled = &cur, s.At
+	if !cur.Running {
+		return
+	}
+
+	var elapsed float64
+	if prev != nil && prev.Running {
+		elapsed = s.At.Sub(prevAt).Seconds()
+	}
+
+	cpu := w.metric("cpu", "CPU")
+	cpu.Max = 100
+	if elapsed > 0 {
+		pct := float64(cur.Usage.Cpu-prev.Usage.Cpu) / (elapsed * float64(time.Second)) * 100
+		cpu.push(pct, fmt.Sprintf("%.1f%%", pct))
+	}
+
+	w.metric("memory", "Memory").push(float64(cur.Usage.Memory), humanize.IBytes(uint64(cur.Usage.Memory)))
+	w.metric("processes", "Processes").push(float64(cur.Usage.Processes), fmt.Sprint(cur.Usage.Processes))
+	for n, usage := range cur.Usage.Disks {
+		w.metric("disk:"+n, "Disk "+n).push(float64(usage), humanize.IBytes(uint64(usage)))
+	}
+
+	if elapsed == 0 {
+		return
+	}
+	for n, iface := range cur.NetworkInterfaces {
+		old, ok := prev.NetworkInterfaces[n]
+		if !ok {
+			continue
+		}
+
+		rx := float64(iface.Counters.BytesReceived-old.Counters.BytesReceived) / elapsed
+		tx := float64(iface.Counters.BytesSent-old.Counters.BytesSent) / elapsed
+		w.metric("rx:"+n, n+" in").push(rx, humanize.IBytes(uint64(math.Max(rx, 0)))+"/s")
+		w.metric("tx:"+n, n+" out").push(tx, humanize.IBytes(uint64(math.Max(tx, 0)))+"/s")
+	}
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline graphs the last width values
+func sparkline(values []float64, width int, max float64) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+
+	if max == 0 {
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > 0 {
+			i = int(math.Round(v / max * float64(len(sparkBlocks)-1)))
+		}
+		if i < 0 {
+			i = 0
+		} else if i >= len(sparkBlocks) {
+			i = len(sparkBlocks) - 1
+		}
+
+		b.WriteRune(sparkBlocks[i])
+	}
+
+	return b.String()
+}
+
+// truncate cuts s to at most width runes
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+
+	return s
+}
+
+// renderTop draws all webspaces to fit in the terminal
+func renderTop(webspaces []*topWebspace, size util.ConsoleSize, interval time.Duration) string {
+	const labelWidth, valueWidth = 14, 12
+	lines := []string{fmt.Sprintf("netsoc webspace top - every %v - %v - q to quit", interval,
+		time.Now().Format("15:04:05"))}
+
+	for _, w := range webspaces {
+		lines = append(lines, "")
+
+		switch {
+		case w.State == nil && w.Err != nil:
+			lines = append(lines, fmt.Sprintf("%v: %v", w.User, w.Err))
+			continue
+		case w.State == nil:
+			lines = append(lines, fmt.Sprintf("%v: loading...", w.User))
+			continue
+		}
+
+		status := "stopped"
+		if w.State.Running {
+			status = fmt.Sprintf("running, up %v",
+				time.Duration(w.State.Uptime*float64(time.Second)).Round(time.Second))
+		}
+		if w.Err != nil {
+			status += fmt.Sprintf(" (update failed: %v)", w.Err)
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v", w.User, status))
+		if !w.State.Running {
+			continue
+		}
+
+		keys := make([]string, 0, len(w.metrics))
+		for k := range w.metrics {
+			if k != "cpu" && k != "memory" && k != "processes" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		keys = append([]string{"cpu", "memory", "processes"}, keys...)
+
+		for _, k := range keys {
+			m, ok := w.metrics[k]
+			if !ok {
+				continue
+			}
+
+			graph := sparkline(m.History, size.Width-labelWidth-valueWidth-4, m.Max)
+			lines = append(lines, fmt.Sprintf("  %-*v%-*v  %v", labelWidth, truncate(m.Label, labelWidth-1),
+				valueWidth, m.Value, graph))
+		}
+	}
+
+	if size.Height > 0 && len(lines) > size.Height {
+		lines = lines[:size.Height]
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncate(l, size.Width))
+		// Clear the rest of the line
+		b.WriteString("\x1b[K")
+	}
+	// Clear the rest of the screen
+	b.WriteString("\x1b[J")
+
+	return b.String()
+}
+
+func topRun(opts topOptions) error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("invalid interval %v", opts.Interval)
+	}
+	if !util.IsInteractive() || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return errors.New("webspace top requires a terminal (try `netsoc webspace status --watch` instead)")
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), webspaced.ContextAccessToken, token)
+
+	webspaces := make([]*topWebspace, len(opts.Users))
+	for i, u := range opts.Users {
+		webspaces[i] = &topWebspace{User: u, metrics: map[string]*topMetric{}}
+	}
+
+	// Only one request per webspace is in flight at a time, so sending never blocks (even after quitting)
+	samples := make(chan topSample, len(webspaces))
+	poll := func() {
+		for i, w := range webspaces {
+			if w.polling {
+				// Skip webspaces whose last request is taking longer than the interval
+				continue
+			}
+			w.polling = true
+
+			go func(i int, user string) {
+				state, _, err := client.StateApi.GetState(ctx, user)
+				if err != nil {
+					err = util.APIError(err)
+				}
+
+				samples <- topSample{Index: i, State: state, Err: err, At: time.Now()}
+			}(i, w.User)
+		}
+	}
+
+	tty := console.Current()
+	s, err := tty.Size()
+	if err != nil {
+		return fmt.Errorf("failed to get terminal size: %w", err)
+	}
+	size := util.ConsoleSize{Width: int(s.Width), Height: int(s.Height)}
+
+	if err := tty.SetRaw(); err != nil {
+		return fmt.Errorf("failed to put terminal in raw mode: %w", err)
+	}
+	defer tty.Reset()
+
+	// Use the alternate screen and hide the cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	resizeChan := make(chan util.ConsoleSize)
+	stop := make(chan struct{})
+	defer close(stop)
+	go util.ResizeListener(resizeChan, stop)
+
+	quit := make(chan struct{})
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := os.Stdin.Read(b); err != nil {
+				close(quit)
+				return
+			}
+
+			// q, Ctrl+C or Ctrl+D
+			if b[0] == 'q' || b[0] == 0x03 || b[0] == 0x04 {
+				close(quit)
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	poll()
+	fmt.Print(renderTop(webspaces, size, opts.Interval))
+	for {
+		select {
+		case s := <-samples:
+			webspaces[s.Index].update(s)
+		case size = <-resizeChan:
+		case <-ticker.C:
+			poll()
+			continue
+		case <-quit:
+			return nil
+		}
+
+		fmt.Print(renderTop(webspaces, size, opts.Interval))
+	}
+}
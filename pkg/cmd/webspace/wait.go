@@ -0,0 +1,500 @@
+package webspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"github.com/netsoc/cli/pkg/printer"
+	"github.com/netsoc/cli/pkg/util"
+	webspaced "github.com/netsoc/webspaced/client"
+)
+
+// waitOptions configures waiting for a webspace to be ready after changing its state
+type waitOptions struct {
+	Enabled bool
+	Timeout time.Duration
+	// For is a list of readiness probes (tcp:<port> or exec:<command>)
+	For []string
+}
+
+// enabled checks if waiting is enabled (readiness probes imply --wait)
+func (o waitOptions) enabled() bool {
+	return o.Enabled || len(o.For) != 0
+}
+
+// addOptWait adds options to wait for a webspace to be ready (optionally with readiness probes)
+func addOptWait(cmd *cobra.Command, o *waitOptions, probes bool) {
+	cmd.Flags().BoolVar(&o.Enabled, "wait", false, "wait for the webspace to be ready")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", 2*time.Minute, "how long to wait before giving up")
+	if probes {
+		cmd.Flags().StringArrayVar(&o.For, "wait-for", []string{},
+			"readiness `probe` to wait for (tcp:<port> or exec:<command>, implies --wait)")
+	}
+}
+
+// waitHelp describes --wait and readiness probes
+const waitHelp = `
+With --wait, the command waits until the webspace is in the expected state
+(and has network addresses if it's running). --wait-for adds readiness probes
+which must pass too, either tcp:<port> (something is listening on the port)
+or exec:<command> (the command exits successfully). Probes are run through
+the exec API. If the webspace isn't ready within --timeout, the exit code is
+124 (other failures exit with code 1).
+`
+
+// waitProbe is a readiness check run in a webspace
+type waitProbe struct {
+	Name    string
+	Command string
+}
+
+func parseProbes(specs []string) ([]waitProbe, error) {
+	probes := make([]waitProbe, len(specs))
+	for i, spec := range specs {
+		split := strings.SplitN(spec, ":", 2)
+		if len(split) != 2 || split[1] == "" {
+			return nil, fmt.Errorf("invalid readiness probe %q (expected tcp:<port> or exec:<command>)", spec)
+		}
+
+		probes[i].Name = spec
+		switch split[0] {
+		case "tcp":
+			port, err := strconv.ParseUint(split[1], 10, 16)
+			if err != nil || port == 0 {
+				return nil, fmt.Errorf("invalid port in readiness probe %q", spec)
+			}
+
+			// Check for a listening socket (state 0A) without relying on any tools being installed
+			probes[i].Command = fmt.Sprintf(
+				"grep -qE '^ *[0-9]+: [0-9A-F]+:%04X [0-9A-F]+:[0-9A-F]+ 0A ' /proc/net/tcp /proc/net/tcp6", port)
+		case "exec":
+			probes[i].Command = split[1]
+		default:
+			return nil, fmt.Errorf("unknown readiness probe type %q (expected tcp or exec)", split[0])
+		}
+	}
+
+	return probes, nil
+}
+
+// pollBackoff calls check (with increasing delays) until it returns true, an error or the context is done
+func pollBackoff(ctx context.Context, check func() (bool, error)) error {
+	delay := 500 * time.Millisecond
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		if delay *= 2; delay > 5*time.Second {
+			delay = 5 * time.Second
+		}
+	}
+}
+
+// hasAddresses checks if a running webspace's network interfaces (other than loopback) have addresses
+func hasAddresses(state webspaced.State) bool {
+	n := 0
+	for name, iface := range state.NetworkInterfaces {
+		if name == "lo" {
+			continue
+		}
+		if len(iface.Addresses) == 0 {
+			return false
+		}
+
+		n++
+	}
+
+	return n != 0
+}
+
+// waitForWebspace waits for a webspace to be running (or stopped, or either if running is nil) and have network
+// addresses if it's running, followed by any readiness probes passing. If opts.Timeout is exceeded, the error is a
+// util.ExitCodeError with util.ExitCodeTimeout.
+func waitForWebspace(client *webspaced.APIClient, token, user string, running *bool, opts waitOptions,
+	probes []waitProbe) error {
+	ctx, cancel := context.WithTimeout(
+		context.WithValue(context.Background(), webspaced.ContextAccessToken, token), opts.Timeout)
+	defer cancel()
+
+	await, _, t := util.SimpleProgress("Waiting for webspace", 0)
+	waitingFor := "webspace"
+	err := waitForWebspaceCtx(ctx, client, user, running, probes, func(s string) {
+		waitingFor = s
+		t.UpdateMessage("Waiting for " + s)
+	})
+	if err != nil {
+		t.MarkAsErrored()
+	} else {
+		t.MarkAsDone()
+	}
+	await()
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &util.ExitCodeError{
+			Err:  fmt.Errorf("timed out after %v waiting for %v", opts.Timeout, waitingFor),
+			Code: util.ExitCodeTimeout,
+		}
+	}
+
+	return err
+}
+
+func waitForWebspaceCtx(ctx context.Context, client *webspaced.APIClient, user string, running *bool,
+	probes []waitProbe, waitingFor func(string)) error {
+	switch {
+	case running == nil:
+		waitingFor("webspace")
+	case *running:
+		waitingFor("webspace to start")
+	default:
+		waitingFor("webspace to stop")
+	}
+
+	var state webspaced.State
+	err := pollBackoff(ctx, func() (bool, error) {
+		var err error
+		state, _, err = client.StateApi.GetState(ctx, user)
+		if err != nil {
+			return false, apiCheckError(err)
+		}
+
+		if running != nil && state.Running != *running {
+			return false, nil
+		}
+		if state.Running && !hasAddresses(state) {
+			waitingFor("webspace network")
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range probes {
+		if !state.Running {
+			return fmt.Errorf("can't run readiness probe %v, webspace isn't running", p.Name)
+		}
+
+		waitingFor(p.Name)
+		if err := pollBackoff(ctx, func() (bool, error) {
+			result, _, err := client.ConsoleApi.Exec(ctx, user, webspaced.ExecRequest{Command: p.Command})
+			if err != nil {
+				return false, apiCheckError(err)
+			}
+
+			util.Debugf("Readiness probe %v exited with code %v", p.Name, result.ExitCode)
+			return result.ExitCode == 0, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type waitCmdOptions struct {
+	Token           func() (string, error)
+	WebspacedClient func() (*webspaced.APIClient, error)
+
+	Output  printer.Options
+	User    string
+	For     []string
+	Timeout time.Duration
+}
+
+// NewCmdWait creates a new webspace wait command
+func NewCmdWait(f *util.CmdFactory) *cobra.Command {
+	opts := waitCmdOptions{
+		Token:           f.Token,
+		WebspacedClient: f.WebspacedClient,
+	}
+	cmd := &cobra.Command{
+		Use:   "wait --for <condition>...",
+		Short: "Wait for webspace condition",
+		Long: heredoc.Doc(`
+			Wait until conditions on a webspace are met (all of them, if --for
+			is given more than once). Conditions are:
+
+			  running       the webspace is running (and has network addresses)
+			  stopped       the webspace exists and isn't running
+			  deleted       the webspace doesn't exist
+			  port=<n>      a port forward to port n in the webspace exists
+			  domain=<d>    the webspace has domain d
+
+			If the conditions aren't met within --timeout, the exit code is 124
+			and the error shows the last status of each condition (other
+			failures exit with code 1). With --output, the status of each
+			condition is also printed (whether or not the wait timed out).
+		`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return waitRun(opts)
+		},
+	}
+
+	util.AddOptProfileUser(cmd, &opts.User)
+	printer.AddFlags(cmd, &opts.Output, "")
+	cmd.Flags().StringArrayVar(&opts.For, "for", []string{}, "`condition` to wait for")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "how long to wait before giving up")
+	cmd.MarkFlagRequired("for")
+
+	return cmd
+}
+
+// waitCondition is the status of a condition being waited for
+type waitCondition struct {
+	Condition string `json:"condition"`
+	Met       bool   `json:"met"`
+	Status    string `json:"status"`
+
+	check func(ctx context.Context) (bool, string, error)
+}
+
+// waitResult is the result of webspace wait
+type waitResult struct {
+	User       string          `json:"user"`
+	TimedOut   bool            `json:"timed_out"`
+	Elapsed    float64         `json:"elapsed"`
+	Conditions []waitCondition `json:"conditions"`
+}
+
+func init() {
+	printer.Register(waitResult{}, printer.TableSpec{
+		Rows: func(data interface{}) []interface{} {
+			conditions := data.(waitResult).Conditions
+			rows := make([]interface{}, len(conditions))
+			for i, c := range conditions {
+				rows[i] = c
+			}
+
+			return rows
+		},
+		Columns: []printer.Column{
+			{Header: "Condition", Value: func(i interface{}) string {
+				return i.(waitCondition).Condition
+			}},
+			{Header: "Met", Value: func(i interface{}) string {
+				return strconv.FormatBool(i.(waitCondition).Met)
+			}},
+			{Header: "Status", Value: func(i interface{}) string {
+				return i.(waitCondition).Status
+			}},
+		},
+	})
+}
+
+// notFound checks if an API response was 404 Not Found (i.e. the webspace doesn't exist)
+func notFound(res *http.Response) bool {
+	return res != nil && res.StatusCode == http.StatusNotFound
+}
+
+// apiCheckError converts an error from an API call made while waiting, leaving context errors as they are
+func apiCheckError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return util.APIError(err)
+}
+
+// parseWaitCondition creates a check for a condition
+func parseWaitCondition(client *webspaced.APIClient, user, spec string) (waitCondition, error) {
+	c := waitCondition{Condition: spec}
+
+	name, arg := spec, ""
+	if i := strings.Index(spec, "="); i != -1 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+
+	checkState := func(running bool) func(ctx context.Context) (bool, string, error) {
+		return func(ctx context.Context) (bool, string, error) {
+			state, res, err := client.StateApi.GetState(ctx, user)
+			switch {
+			case notFound(res):
+				return false, "webspace doesn't exist", nil
+			case err != nil:
+				return false, "", apiCheckError(err)
+			case !state.Running:
+				return !running, "stopped", nil
+			case !hasAddresses(state):
+				return false, "running (no network addresses)", nil
+			default:
+				return running, "running", nil
+			}
+		}
+	}
+
+	switch {
+	case name == "running" && arg == "":
+		c.check = checkState(true)
+	case name == "stopped" && arg == "":
+		c.check = checkState(false)
+	case name == "deleted" && arg == "":
+		c.check = func(ctx context.Context) (bool, string, error) {
+			_, res, err := client.ConfigApi.GetConfig(ctx, user)
+			switch {
+			case notFound(res):
+				return true, "webspace doesn't exist", nil
+			case err != nil:
+				return false, "", apiCheckError(err)
+			default:
+				return false, "webspace exists", nil
+			}
+		}
+	case name == "port" && arg != "":
+		port, err := strconv.ParseUint(arg, 10, 16)
+		if err != nil || port == 0 {
+			return c, fmt.Errorf("invalid port in condition %q", spec)
+		}
+
+		c.check = func(ctx context.Context) (bool, string, error) {
+			ports, res, err := client.PortsApi.GetPorts(ctx, user)
+			switch {
+			case notFound(res):
+				return false, "webspace doesn't exist", nil
+			case err != nil:
+				return false, "", apiCheckError(err)
+			}
+
+			for e, i := range ports {
+				if i == int32(port) {
+					return true, fmt.Sprintf("forwarded from port %v", e), nil
+				}
+			}
+			return false, "not forwarded", nil
+		}
+	case name == "domain" && arg != "":
+		c.check = func(ctx context.Context) (bool, string, error) {
+			domains, res, err := client.DomainsApi.GetDomains(ctx, user)
+			switch {
+			case notFound(res):
+				return false, "webspace doesn't exist", nil
+			case err != nil:
+				return false, "", apiCheckError(err)
+			}
+
+			for _, d := range domains {
+				if strings.EqualFold(d, arg) {
+					return true, "present", nil
+				}
+			}
+			return false, "not present", nil
+		}
+	default:
+		return c, fmt.Errorf("invalid condition %q (expected running, stopped, deleted, port=<n> or domain=<d>)", spec)
+	}
+
+	return c, nil
+}
+
+func waitRun(opts waitCmdOptions) error {
+	if opts.Output.Format != "" {
+		// Check the format is valid before waiting
+		if err := printer.Fprint(ioutil.Discard, waitResult{}, opts.Output); err != nil {
+			return err
+		}
+	}
+
+	token, err := opts.Token()
+	if err != nil {
+		return err
+	}
+
+	client, err := opts.WebspacedClient()
+	if err != nil {
+		return err
+	}
+
+	result := waitResult{
+		User:       opts.User,
+		Conditions: make([]waitCondition, len(opts.For)),
+	}
+	for i, spec := range opts.For {
+		if result.Conditions[i], err = parseWaitCondition(client, opts.User, spec); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.WithValue(context.Background(), webspaced.ContextAccessToken, token), opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	await, _, t := util.SimpleProgress("Waiting for "+strings.Join(opts.For, ", "), 0)
+	err = pollBackoff(ctx, func() (bool, error) {
+		var waiting []string
+		for i, c := range result.Conditions {
+			met, status, err := c.check(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			result.Conditions[i].Met = met
+			result.Conditions[i].Status = status
+			if !met {
+				waiting = append(waiting, fmt.Sprintf("%v (%v)", c.Condition, status))
+			}
+		}
+
+		if len(waiting) != 0 {
+			t.UpdateMessage("Waiting for " + strings.Join(waiting, ", "))
+			return false, nil
+		}
+		return true, nil
+	})
+	result.Elapsed = time.Since(start).Seconds()
+	if err != nil {
+		t.MarkAsErrored()
+	} else {
+		t.MarkAsDone()
+	}
+	await()
+
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if err != nil && !timedOut {
+		return err
+	}
+	result.TimedOut = timedOut
+
+	if opts.Output.Format != "" {
+		if err := printer.Print(result, opts.Output); err != nil {
+			return err
+		}
+	}
+	if !timedOut {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "timed out after %v waiting for webspace of user %v:", opts.Timeout, opts.User)
+	for _, c := range result.Conditions {
+		met := "not met"
+		if c.Met {
+			met = "met"
+		}
+		fmt.Fprintf(&b, "\n  %v: %v (%v)", c.Condition, met, c.Status)
+	}
+
+	return &util.ExitCodeError{Err: errors.New(b.String()), Code: util.ExitCodeTimeout}
+}
@@ -15,17 +15,24 @@ func NewCmdWebspace(f *util.CmdFactory) *cobra.Command {
 	}
 
 	// images
-	cmd.AddCommand(NewCmdImages(f))
+	cmd.AddCommand(NewCmdImages(f), NewCmdTemplates(f))
 	// config
-	cmd.AddCommand(NewCmdInit(f), NewCmdDelete(f), NewCmdConfig(f))
+	cmd.AddCommand(NewCmdInit(f), NewCmdDelete(f), NewCmdConfig(f), NewCmdProvision(f), NewCmdClone(f))
+	// manifests
+	cmd.AddCommand(NewCmdApply(f), NewCmdDiff(f), NewCmdExport(f))
 	// state
-	cmd.AddCommand(NewCmdStatus(f), NewCmdStart(f), NewCmdSync(f), NewCmdReboot(f), NewCmdStop(f))
+	cmd.AddCommand(NewCmdStatus(f), NewCmdStart(f), NewCmdSync(f), NewCmdReboot(f), NewCmdStop(f), NewCmdTop(f),
+		NewCmdWait(f))
 	// domains
 	cmd.AddCommand(NewCmdDomains(f))
 	// ports
-	cmd.AddCommand(NewCmdPorts(f))
+	cmd.AddCommand(NewCmdPorts(f), NewCmdPortForward(f), NewCmdProxy(f))
 	// console
 	cmd.AddCommand(NewCmdLog(f), NewCmdConsole(f), NewCmdExec(f), NewCmdLogin(f))
+	// ssh
+	cmd.AddCommand(NewCmdSSHConfig(f), NewCmdSSH(f))
+	// files
+	cmd.AddCommand(NewCmdCp(f), NewCmdSyncDir(f), NewCmdBackup(f), NewCmdRestore(f))
 
 	return cmd
 }
@@ -1,12 +1,35 @@
 package config
 
 import (
+	"regexp"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
+// DefaultProfile is the name of the profile used when none has been selected
+const DefaultProfile = "default"
+
+const (
+	// CredentialStoreConfig stores tokens in plaintext in the config file
+	CredentialStoreConfig = "config"
+	// CredentialStoreEncrypted stores tokens in a passphrase (or key file) encrypted file
+	CredentialStoreEncrypted = "encrypted"
+	// CredentialStorePass stores tokens using pass (https://www.passwordstore.org)
+	CredentialStorePass = "pass"
+	// CredentialStoreHelper uses an external credential helper program
+	CredentialStoreHelper = "helper"
+)
+
+var profileNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidProfileName checks if a profile name is valid (viper keys are case insensitive and split on dots, so names are
+// restricted to lowercase alphanumerics, dashes and underscores)
+func ValidProfileName(name string) bool {
+	return profileNameRegex.MatchString(name)
+}
+
 // DecoderOptions enables necessary mapstructure decode hook functions
 func DecoderOptions(config *mapstructure.DecoderConfig) {
 	config.ErrorUnused = true
@@ -16,26 +39,76 @@ func DecoderOptions(config *mapstructure.DecoderConfig) {
 	)
 }
 
+// SetProfileDefaults sets defaults for a profile
+func SetProfileDefaults(name string) {
+	prefix := "profiles." + name + "."
+	viper.SetDefault(prefix+"token", "")
+	viper.SetDefault(prefix+"allow_insecure", false)
+	viper.SetDefault(prefix+"user", "")
+
+	viper.SetDefault(prefix+"urls.iam", "https://iam.netsoc.ie/v1")
+	viper.SetDefault(prefix+"urls.webspaced", "https://webspaced.netsoc.ie/v1")
+}
+
 // SetDefaults sets config defaults
 func SetDefaults() {
 	viper.SetDefault("debug", false)
-	viper.SetDefault("token", "")
-	viper.SetDefault("allow_insecure", false)
+	viper.SetDefault("current_profile", DefaultProfile)
+	viper.SetDefault("token_expiry_warning", 72*time.Hour)
 
-	viper.SetDefault("urls.iam", "https://iam.netsoc.ie/v1")
-	viper.SetDefault("urls.webspaced", "https://webspaced.netsoc.ie/v1")
+	viper.SetDefault("credential_store.type", CredentialStoreConfig)
+	viper.SetDefault("credential_store.helper", "")
+	viper.SetDefault("credential_store.file", "")
+	viper.SetDefault("credential_store.key_file", "")
+	viper.SetDefault("credential_store.pass_prefix", "netsoc")
+
+	SetProfileDefaults(DefaultProfile)
 }
 
-// Config represents the Netsoc CLI config
-type Config struct {
-	Debug         bool
+// URLs represents the base URLs of Netsoc services
+type URLs struct {
+	IAM       string
+	Webspaced string
+}
+
+// Profile represents the connection details and credentials for a Netsoc deployment
+type Profile struct {
+	// Token is stored in plaintext in the config file (or set from the environment), use Config.Token() to look up
+	// the token from the credential store
 	Token         string
 	AllowInsecure bool `mapstructure:"allow_insecure"`
+	User          string
+
+	URLs URLs
+}
 
-	URLs struct {
-		IAM       string
-		Webspaced string
-	}
+// CredentialStore represents credential store options
+type CredentialStore struct {
+	Type string
+	// Helper is the name (netsoc-credential-<name>) or path of an external credential helper
+	Helper string
+	// File is the path to the encrypted credentials file (defaults to alongside the config file)
+	File string
+	// KeyFile is used instead of a passphrase to encrypt the credentials file if set
+	KeyFile    string `mapstructure:"key_file"`
+	PassPrefix string `mapstructure:"pass_prefix"`
+}
+
+// Config represents the Netsoc CLI config
+type Config struct {
+	Debug           bool
+	CurrentProfile  string `mapstructure:"current_profile"`
+	Profiles        map[string]*Profile
+	CredentialStore CredentialStore `mapstructure:"credential_store"`
+	// TokenExpiryWarning is how long before a token expires to start warning about it (0 to disable)
+	TokenExpiryWarning time.Duration `mapstructure:"token_expiry_warning"`
 
 	LastUpdateCheck time.Time `mapstructure:"last_update_check"`
+
+	// ProfileName is the name of the selected profile (which might be overridden by flags or the environment)
+	ProfileName string `mapstructure:"-"`
+	// Profile is the selected profile
+	*Profile `mapstructure:"-"`
+	// Token looks up the selected profile's token (from the environment, config file or credential store)
+	Token func() (string, error) `mapstructure:"-"`
 }
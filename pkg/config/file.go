@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// legacyProfileKeys are top-level keys which were moved into profiles
+var legacyProfileKeys = []string{"token", "allow_insecure", "urls"}
+
+// File represents the raw contents of a config file on disk
+type File struct {
+	Path string
+	Data map[string]interface{}
+}
+
+// normalize converts the map[interface{}]interface{} values produced by the YAML decoder into map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// ReadFile reads a config file, returning an empty one if it doesn't exist
+func ReadFile(path string) (*File, error) {
+	f := &File{
+		Path: path,
+		Data: map[string]interface{}{},
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if raw != nil {
+		f.Data = normalize(raw).(map[string]interface{})
+	}
+
+	return f, nil
+}
+
+// Parse parses raw YAML config data, ensuring it is valid
+func Parse(data []byte) (map[string]interface{}, error) {
+	var raw map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if raw == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	m := normalize(raw).(map[string]interface{})
+	if err := Validate(m); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return m, nil
+}
+
+// WriteAtomic replaces a file with new contents atomically (by writing to a temporary file in the same directory
+// and renaming it over the original)
+func WriteAtomic(path string, data []byte) error {
+	// Replace the target of a symlink (e.g. into a dotfiles repo) rather than the link itself
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+
+	var mode os.FileMode = 0600
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set permissions on temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// Write (atomically) writes the config file back to disk
+func (f *File) Write() error {
+	data, err := yaml.Marshal(f.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := WriteAtomic(f.Path, data); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
+// Get gets the value of a key set in the file
+func (f *File) Get(key string) (interface{}, bool) {
+	var v interface{} = f.Data
+	for _, p := range splitKey(key) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if v, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// Set validates and sets the value of a key in the file
+func (f *File) Set(key string, value interface{}) error {
+	v, err := Convert(key, value)
+	if err != nil {
+		return err
+	}
+
+	path := splitKey(key)
+	m := f.Data
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+	if d, ok := v.(time.Duration); ok {
+		// Durations would otherwise be written as a number of nanoseconds
+		v = d.String()
+	}
+	m[path[len(path)-1]] = v
+
+	return nil
+}
+
+// Unset removes a key from the file, returning false if it wasn't set
+func (f *File) Unset(key string) (bool, error) {
+	if err := CheckKey(key); err != nil {
+		return false, err
+	}
+
+	path := splitKey(key)
+	m := f.Data
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return false, nil
+		}
+		m = next
+	}
+
+	if _, ok := m[path[len(path)-1]]; !ok {
+		return false, nil
+	}
+	delete(m, path[len(path)-1])
+
+	return true, nil
+}
+
+// Migrate moves top-level profile settings from a config file predating profiles into the default profile, returning
+// true if anything was changed
+func (f *File) Migrate() bool {
+	legacy := map[string]interface{}{}
+	for _, k := range legacyProfileKeys {
+		if v, ok := f.Data[k]; ok {
+			legacy[k] = v
+			delete(f.Data, k)
+		}
+	}
+	if len(legacy) == 0 {
+		return false
+	}
+
+	p := f.Profile(DefaultProfile)
+	for k, v := range legacy {
+		if _, ok := p[k]; !ok {
+			p[k] = v
+		}
+	}
+
+	if _, ok := f.Data["current_profile"]; !ok {
+		f.Data["current_profile"] = DefaultProfile
+	}
+
+	return true
+}
+
+// Profiles returns the (mutable) map of profiles in the file, creating it if necessary
+func (f *File) Profiles() map[string]interface{} {
+	profiles, ok := f.Data["profiles"].(map[string]interface{})
+	if !ok {
+		profiles = map[string]interface{}{}
+		f.Data["profiles"] = profiles
+	}
+
+	return profiles
+}
+
+// Profile returns the (mutable) map of settings for a profile in the file, creating it if necessary
+func (f *File) Profile(name string) map[string]interface{} {
+	profiles := f.Profiles()
+	p, ok := profiles[name].(map[string]interface{})
+	if !ok {
+		p = map[string]interface{}{}
+		profiles[name] = p
+	}
+
+	return p
+}
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ErrUnknownKey indicates that a config key doesn't exist
+var ErrUnknownKey = errors.New("unknown config key")
+
+// SourceKind represents a layer from which a config value can originate
+type SourceKind int
+
+const (
+	// SourceDefault indicates a value is the built-in default
+	SourceDefault SourceKind = iota
+	// SourceFile indicates a value was read from the config file
+	SourceFile
+	// SourceEnv indicates a value was read from an environment variable
+	SourceEnv
+	// SourceFlag indicates a value was set from a command line flag
+	SourceFlag
+)
+
+// Source describes where a config value came from
+type Source struct {
+	Kind SourceKind
+	// Name is the environment variable or flag name (if applicable)
+	Name string
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return fmt.Sprintf("env (%v)", s.Name)
+	case SourceFlag:
+		return fmt.Sprintf("flag (--%v)", s.Name)
+	default:
+		return "default"
+	}
+}
+
+// fieldName returns the config key name for a struct field (or "" if it should be skipped)
+func fieldName(f reflect.StructField) string {
+	name := strings.ToLower(f.Name)
+	if tag, ok := f.Tag.Lookup("mapstructure"); ok {
+		tag = strings.Split(tag, ",")[0]
+		if tag == "-" {
+			return ""
+		}
+		if tag != "" {
+			name = tag
+		}
+	}
+
+	return name
+}
+
+func isLeaf(t reflect.Type) bool {
+	return t == timeType || (t.Kind() != reflect.Struct && t.Kind() != reflect.Map)
+}
+
+func splitKey(key string) []string {
+	return strings.Split(strings.ToLower(key), ".")
+}
+
+// lookupType finds the type of the value pointed to by a key
+func lookupType(t reflect.Type, path []string) (reflect.Type, error) {
+	for _, p := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch {
+		case isLeaf(t):
+			return nil, ErrUnknownKey
+		case t.Kind() == reflect.Map:
+			if !ValidProfileName(p) {
+				return nil, fmt.Errorf("invalid profile name %q", p)
+			}
+			t = t.Elem()
+		default:
+			found := false
+			for i := 0; i < t.NumField(); i++ {
+				if fieldName(t.Field(i)) == p {
+					t = t.Field(i).Type
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, ErrUnknownKey
+			}
+		}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t, nil
+}
+
+// lookupValue finds the value pointed to by a key
+func lookupValue(v reflect.Value, path []string) (reflect.Value, bool) {
+	for _, p := range path {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(p))
+			if !v.IsValid() {
+				return reflect.Value{}, false
+			}
+		case reflect.Struct:
+			found := false
+			for i := 0; i < v.NumField(); i++ {
+				if fieldName(v.Type().Field(i)) == p {
+					v = v.Field(i)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return reflect.Value{}, false
+			}
+		default:
+			return reflect.Value{}, false
+		}
+	}
+
+	return v, true
+}
+
+// leafKeys lists all leaf keys (in order) of a value
+func leafKeys(v reflect.Value, prefix string) []string {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if isLeaf(v.Type()) {
+		return []string{prefix}
+	}
+
+	var keys []string
+	if v.Kind() == reflect.Map {
+		names := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			names = append(names, k.String())
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			keys = append(keys, leafKeys(v.MapIndex(reflect.ValueOf(n)), prefix+n+".")...)
+		}
+		return keys
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		if n := fieldName(v.Type().Field(i)); n != "" {
+			keys = append(keys, leafKeys(v.Field(i), prefix+n+".")...)
+		}
+	}
+	return keys
+}
+
+// Keys lists all leaf keys present in a config
+func Keys(c *Config) []string {
+	keys := leafKeys(reflect.ValueOf(c), "")
+	for i, k := range keys {
+		keys[i] = strings.TrimSuffix(k, ".")
+	}
+
+	return keys
+}
+
+// ProfileKeys lists the keys within a profile
+func ProfileKeys() []string {
+	keys := leafKeys(reflect.ValueOf(Profile{}), "")
+	for i, k := range keys {
+		keys[i] = strings.TrimSuffix(k, ".")
+	}
+
+	return keys
+}
+
+// ExpandKey converts a key which refers to a profile setting without the `profiles.<name>` prefix to its full form
+func ExpandKey(key, profile string) string {
+	key = strings.ToLower(key)
+	if _, err := lookupType(reflect.TypeOf(Profile{}), splitKey(key)); err == nil {
+		return "profiles." + profile + "." + key
+	}
+
+	return key
+}
+
+// EnvVars returns the environment variables which can set a key (in order of decreasing precedence), given the
+// selected profile
+func EnvVars(key, profile string) []string {
+	key = strings.ToLower(key)
+	automatic := "NETSOC_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+
+	prefix := "profiles." + profile + "."
+	switch {
+	case strings.HasPrefix(key, prefix):
+		short := strings.TrimPrefix(key, prefix)
+		return []string{"NETSOC_" + strings.ToUpper(strings.ReplaceAll(short, ".", "_")), automatic}
+	case key == "current_profile":
+		return []string{"NETSOC_PROFILE", automatic}
+	default:
+		return []string{automatic}
+	}
+}
+
+// CheckKey ensures a key refers to a single config value
+func CheckKey(key string) error {
+	t, err := lookupType(reflect.TypeOf(Config{}), splitKey(key))
+	if err != nil {
+		return fmt.Errorf("%w: %v", err, key)
+	}
+	if !isLeaf(t) {
+		return fmt.Errorf("%v is not a single value (try one of its sub-keys)", key)
+	}
+
+	return nil
+}
+
+// Lookup gets the value of a key from a config
+func Lookup(c *Config, key string) (interface{}, error) {
+	if err := CheckKey(key); err != nil {
+		return nil, err
+	}
+
+	v, ok := lookupValue(reflect.ValueOf(c), splitKey(key))
+	if !ok {
+		return nil, fmt.Errorf("%v is not set", key)
+	}
+
+	return v.Interface(), nil
+}
+
+// nest builds a nested map with a single value from a key
+func nest(path []string, value interface{}) map[string]interface{} {
+	m := map[string]interface{}{path[len(path)-1]: value}
+	for i := len(path) - 2; i >= 0; i-- {
+		m = map[string]interface{}{path[i]: m}
+	}
+
+	return m
+}
+
+// newDecoder creates a decoder equivalent to the one used by viper.Unmarshal() (with DecoderOptions applied)
+func newDecoder(result interface{}, weak bool) (*mapstructure.Decoder, error) {
+	dc := &mapstructure.DecoderConfig{
+		Result:           result,
+		WeaklyTypedInput: weak,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	}
+	DecoderOptions(dc)
+
+	d, err := mapstructure.NewDecoder(dc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decoder: %w", err)
+	}
+
+	return d, nil
+}
+
+// Convert validates a key and converts a value (e.g. a string from the command line) to the key's type, using the
+// same decoding options as when loading the config
+func Convert(key string, value interface{}) (interface{}, error) {
+	if err := CheckKey(key); err != nil {
+		return nil, err
+	}
+
+	path := splitKey(key)
+	var c Config
+	decoder, err := newDecoder(&c, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decoder.Decode(nest(path, value)); err != nil {
+		return nil, fmt.Errorf("invalid value for %v: %w", key, err)
+	}
+
+	v, _ := lookupValue(reflect.ValueOf(&c), path)
+	return v.Interface(), nil
+}
+
+// Validate checks that raw config data (e.g. the contents of a config file) is valid
+func Validate(data map[string]interface{}) error {
+	var c Config
+	decoder, err := newDecoder(&c, false)
+	if err != nil {
+		return err
+	}
+
+	if err := decoder.Decode(data); err != nil {
+		return err
+	}
+
+	for name := range c.Profiles {
+		if !ValidProfileName(name) {
+			return fmt.Errorf("invalid profile name %q", name)
+		}
+	}
+
+	return nil
+}
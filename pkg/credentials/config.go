@@ -0,0 +1,130 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/netsoc/cli/pkg/config"
+)
+
+// ConfigStore stores tokens in plaintext in the config file
+type ConfigStore struct {
+	File func() (*config.File, error)
+}
+
+func tokenKey(profile string) string {
+	return "profiles." + profile + ".token"
+}
+
+// Get retrieves the token for a profile
+func (s *ConfigStore) Get(profile string) (string, error) {
+	f, err := s.File()
+	if err != nil {
+		return "", err
+	}
+
+	t, _ := f.Get(tokenKey(profile))
+	if token, ok := t.(string); ok && token != "" {
+		return token, nil
+	}
+
+	return "", ErrNotFound
+}
+
+// Store saves the token for a profile
+func (s *ConfigStore) Store(profile, token string) error {
+	f, err := s.File()
+	if err != nil {
+		return err
+	}
+
+	if err := f.Set(tokenKey(profile), token); err != nil {
+		return err
+	}
+
+	return f.Write()
+}
+
+// Erase removes the token for a profile
+func (s *ConfigStore) Erase(profile string) error {
+	f, err := s.File()
+	if err != nil {
+		return err
+	}
+
+	removed, err := f.Unset(tokenKey(profile))
+	if err != nil || !removed {
+		return err
+	}
+
+	return f.Write()
+}
+
+// Token looks up the token for a profile. A plaintext token (set in the config file or environment) takes precedence
+// over the one in the store, which is only set up if needed. The token is empty if there is none.
+func Token(store func() (Store, error), profile, plaintext string) (string, error) {
+	if plaintext != "" {
+		return plaintext, nil
+	}
+
+	s, err := store()
+	if err != nil {
+		return "", err
+	}
+
+	t, err := s.Get(profile)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve token from credential store: %w", err)
+	}
+
+	return t, nil
+}
+
+// StoreToken saves a token in a store, removing any plaintext token left in the config file (which would otherwise
+// take precedence)
+func StoreToken(s Store, file func() (*config.File, error), profile, token string) error {
+	if err := s.Store(profile, token); err != nil {
+		return err
+	}
+
+	if _, plaintext := s.(*ConfigStore); plaintext {
+		return nil
+	}
+	return (&ConfigStore{File: file}).Erase(profile)
+}
+
+// EraseToken removes a token from a store, along with any plaintext token in the config file
+func EraseToken(s Store, file func() (*config.File, error), profile string) error {
+	if err := s.Erase(profile); err != nil {
+		return err
+	}
+
+	if _, plaintext := s.(*ConfigStore); plaintext {
+		return nil
+	}
+	return (&ConfigStore{File: file}).Erase(profile)
+}
+
+// MoveToken moves a token between profiles (a token stored in the config file moves along with its profile, so this
+// is a no-op for ConfigStore)
+func MoveToken(s Store, from, to string) error {
+	if _, plaintext := s.(*ConfigStore); plaintext {
+		return nil
+	}
+
+	t, err := s.Get(from)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.Store(to, t); err != nil {
+		return err
+	}
+	return s.Erase(from)
+}
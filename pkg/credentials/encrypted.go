@@ -0,0 +1,212 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/netsoc/cli/pkg/config"
+)
+
+const (
+	saltSize  = 16
+	nonceSize = 24
+	keySize   = 32
+)
+
+// ErrDecrypt indicates that the credentials file couldn't be decrypted (most likely the passphrase was wrong)
+var ErrDecrypt = errors.New("failed to decrypt credentials (wrong passphrase or key?)")
+
+type encryptedFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Box   []byte `json:"box"`
+}
+
+// EncryptedFileStore stores tokens in a file encrypted with a key derived from a passphrase or key file
+type EncryptedFileStore struct {
+	Path string
+	// KeyFile is read for key material instead of asking for a passphrase if set
+	KeyFile string
+	// Passphrase is called to obtain a passphrase (confirm is true if a new file is being created)
+	Passphrase func(confirm bool) (string, error)
+
+	secret []byte
+}
+
+func (s *EncryptedFileStore) getSecret(confirm bool) ([]byte, error) {
+	if s.secret != nil {
+		return s.secret, nil
+	}
+
+	if s.KeyFile != "" {
+		data, err := ioutil.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, errors.New("key file is empty")
+		}
+
+		s.secret = data
+		return s.secret, nil
+	}
+
+	p, err := s.Passphrase(confirm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passphrase: %w", err)
+	}
+	if p == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	s.secret = []byte(p)
+	return s.secret, nil
+}
+
+func deriveKey(secret, salt []byte) (*[keySize]byte, error) {
+	k, err := scrypt.Key(secret, salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	var key [keySize]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+func (s *EncryptedFileStore) read() (map[string]string, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var f encryptedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file: %w", err)
+	}
+	if len(f.Nonce) != nonceSize {
+		return nil, errors.New("invalid nonce in credentials file")
+	}
+
+	secret, err := s.getSecret(false)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(secret, f.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], f.Nonce)
+	plain, ok := secretbox.Open(nil, f.Box, &nonce, key)
+	if !ok {
+		// Don't cache a bad passphrase
+		s.secret = nil
+		return nil, ErrDecrypt
+	}
+
+	var tokens map[string]string
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted credentials: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *EncryptedFileStore) write(tokens map[string]string) error {
+	_, statErr := os.Stat(s.Path)
+	secret, err := s.getSecret(errors.Is(statErr, os.ErrNotExist))
+	if err != nil {
+		return err
+	}
+
+	f := encryptedFile{
+		Salt:  make([]byte, saltSize),
+		Nonce: make([]byte, nonceSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, f.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	key, err := deriveKey(secret, f.Salt)
+	if err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], f.Nonce)
+	f.Box = secretbox.Seal(nil, plain, &nonce, key)
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials file: %w", err)
+	}
+
+	if err := config.WriteAtomic(s.Path, data); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the token for a profile
+func (s *EncryptedFileStore) Get(profile string) (string, error) {
+	tokens, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	t, ok := tokens[profile]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return t, nil
+}
+
+// Store saves the token for a profile
+func (s *EncryptedFileStore) Store(profile, token string) error {
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	tokens[profile] = token
+	return s.write(tokens)
+}
+
+// Erase removes the token for a profile
+func (s *EncryptedFileStore) Erase(profile string) error {
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := tokens[profile]; !ok {
+		return nil
+	}
+
+	delete(tokens, profile)
+	return s.write(tokens)
+}
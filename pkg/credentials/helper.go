@@ -0,0 +1,96 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// HelperStore delegates to an external credential helper program, similar to git's credential helpers.
+//
+// The helper is invoked as `netsoc-credential-<name> get|store|erase` (or by path if the name contains a path
+// separator). Attributes are passed on stdin as `key=value` lines terminated by a blank line; `profile` is always set
+// and `token` is also set for `store`. For `get`, the helper should print `token=<token>` on stdout (or nothing if it
+// has no token for the profile).
+type HelperStore struct {
+	Helper string
+}
+
+func (s *HelperStore) command() string {
+	if filepath.Base(s.Helper) != s.Helper {
+		return s.Helper
+	}
+
+	return "netsoc-credential-" + s.Helper
+}
+
+func (s *HelperStore) run(action string, attrs map[string]string) (map[string]string, error) {
+	var stdin bytes.Buffer
+	for k, v := range attrs {
+		if strings.ContainsAny(v, "\n\x00") {
+			return nil, fmt.Errorf("invalid value for credential attribute %v", k)
+		}
+
+		fmt.Fprintf(&stdin, "%v=%v\n", k, v)
+	}
+	stdin.WriteString("\n")
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(s.command(), action)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	// Helpers might need to prompt the user
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %v failed: %w", s.command(), err)
+	}
+
+	result := map[string]string{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid output line from credential helper: %q", line)
+		}
+		result[split[0]] = split[1]
+	}
+
+	return result, nil
+}
+
+// Get retrieves the token for a profile
+func (s *HelperStore) Get(profile string) (string, error) {
+	result, err := s.run("get", map[string]string{"profile": profile})
+	if err != nil {
+		return "", err
+	}
+
+	t := result["token"]
+	if t == "" {
+		return "", ErrNotFound
+	}
+
+	return t, nil
+}
+
+// Store saves the token for a profile
+func (s *HelperStore) Store(profile, token string) error {
+	_, err := s.run("store", map[string]string{"profile": profile, "token": token})
+	return err
+}
+
+// Erase removes the token for a profile
+func (s *HelperStore) Erase(profile string) error {
+	_, err := s.run("erase", map[string]string{"profile": profile})
+	return err
+}
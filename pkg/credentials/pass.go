@@ -0,0 +1,65 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// PassStore stores tokens using pass (the standard unix password manager, backed by gpg)
+type PassStore struct {
+	// Prefix is the directory within the password store to keep tokens in
+	Prefix string
+}
+
+func (s *PassStore) run(stdin string, args ...string) (string, string, error) {
+	cmd := exec.Command("pass", args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func (s *PassStore) entry(profile string) string {
+	return path.Join(s.Prefix, profile)
+}
+
+// Get retrieves the token for a profile
+func (s *PassStore) Get(profile string) (string, error) {
+	stdout, stderr, err := s.run("", "show", s.entry(profile))
+	if err != nil {
+		if strings.Contains(stderr, "is not in the password store") {
+			return "", ErrNotFound
+		}
+
+		return "", fmt.Errorf("pass show failed: %w: %v", err, strings.TrimSpace(stderr))
+	}
+
+	// The password is on the first line
+	return strings.TrimSpace(strings.SplitN(stdout, "\n", 2)[0]), nil
+}
+
+// Store saves the token for a profile
+func (s *PassStore) Store(profile, token string) error {
+	if _, stderr, err := s.run(token+"\n", "insert", "--multiline", "--force", s.entry(profile)); err != nil {
+		return fmt.Errorf("pass insert failed: %w: %v", err, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// Erase removes the token for a profile
+func (s *PassStore) Erase(profile string) error {
+	_, stderr, err := s.run("", "rm", "--force", s.entry(profile))
+	if err != nil && !strings.Contains(stderr, "is not in the password store") {
+		return fmt.Errorf("pass rm failed: %w: %v", err, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package credentials
+
+import "errors"
+
+// ErrNotFound indicates that no credentials are stored for a profile
+var ErrNotFound = errors.New("credentials not found")
+
+// Store stores tokens for config profiles
+type Store interface {
+	// Get retrieves the token for a profile (returning ErrNotFound if there is none)
+	Get(profile string) (string, error)
+	// Store saves the token for a profile
+	Store(profile, token string) error
+	// Erase removes the token for a profile (it is not an error if there is none)
+	Erase(profile string) error
+}
@@ -0,0 +1,393 @@
+package printer
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// flatField is a field of an object flattened into a single level (nested object fields are joined with ".")
+type flatField struct {
+	Key   string
+	Value interface{}
+}
+
+// flattenJSON encodes v as JSON and flattens it into its fields, preserving their order
+func flattenJSON(v interface{}) ([]flatField, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+
+	var fields []flatField
+	if err := flattenValue(d, "", &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	return fields, nil
+}
+
+func flattenValue(d *json.Decoder, prefix string, fields *[]flatField) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	if t != json.Delim('{') {
+		var v interface{} = t
+		if t == json.Delim('[') {
+			// Lists are kept as a single value
+			var list []interface{}
+			for d.More() {
+				var e interface{}
+				if err := d.Decode(&e); err != nil {
+					return err
+				}
+				list = append(list, e)
+			}
+			if _, err := d.Token(); err != nil {
+				return err
+			}
+			v = list
+		}
+
+		*fields = append(*fields, flatField{prefix, v})
+		return nil
+	}
+
+	for d.More() {
+		k, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		key := k.(string)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if err := flattenValue(d, key, fields); err != nil {
+			return err
+		}
+	}
+
+	_, err = d.Token()
+	return err
+}
+
+// exportCell formats a value for machine-readable output. Timestamps (which are ISO 8601 in JSON) before the Unix
+// epoch are treated as unset and left empty.
+func exportCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil && t.Before(time.Unix(0, 0)) {
+			return ""
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(j)
+}
+
+// scalarHeader is the header of the single column of items which aren't objects (e.g. a list of domains)
+const scalarHeader = "value"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// joinKey joins a flattened field's key onto the key of the object containing it
+func joinKey(prefix, key string) string {
+	switch {
+	case prefix == "":
+		return key
+	case key == "":
+		return prefix
+	}
+	return prefix + "." + key
+}
+
+// fieldKeys lists the (flattened) JSON fields of values of type t in the order encoding/json writes them, so the
+// columns don't depend on which items are present or which of their fields are empty. Map keys can't be known from the
+// type, so they're collected from values and sorted (as encoding/json does).
+func fieldKeys(t reflect.Type, values []reflect.Value, prefix string) ([]string, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+
+		var elems []reflect.Value
+		for _, v := range values {
+			if !v.IsNil() {
+				elems = append(elems, v.Elem())
+			}
+		}
+		values = elems
+	}
+
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		// Types which encode themselves (e.g. time.Time) could produce anything
+		return valueKeys(values, prefix)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		var elems []reflect.Value
+		for _, v := range values {
+			if !v.IsNil() {
+				elems = append(elems, v.Elem())
+			}
+		}
+		return valueKeys(elems, prefix)
+	case reflect.Struct:
+		var keys []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+
+			fields := make([]reflect.Value, len(values))
+			for j, v := range values {
+				fields[j] = v.Field(i)
+			}
+
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			key := joinKey(prefix, name)
+			if name == "" {
+				if f.Anonymous && ft.Kind() == reflect.Struct {
+					// Fields of embedded structs are promoted
+					key = prefix
+				} else if f.PkgPath != "" {
+					continue
+				} else {
+					key = joinKey(prefix, f.Name)
+				}
+			}
+
+			sub, err := fieldKeys(f.Type, fields, key)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sub...)
+		}
+
+		return keys, nil
+	case reflect.Map:
+		var names []string
+		byName := map[string][]reflect.Value{}
+		for _, v := range values {
+			iter := v.MapRange()
+			for iter.Next() {
+				name, err := mapKeyName(iter.Key())
+				if err != nil {
+					return nil, err
+				}
+
+				if _, ok := byName[name]; !ok {
+					names = append(names, name)
+				}
+				byName[name] = append(byName[name], iter.Value())
+			}
+		}
+		sort.Strings(names)
+
+		var keys []string
+		for _, name := range names {
+			sub, err := fieldKeys(t.Elem(), byName[name], joinKey(prefix, name))
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sub...)
+		}
+
+		return keys, nil
+	}
+
+	// Everything else (including lists) is a single value
+	return []string{prefix}, nil
+}
+
+// mapKeyName is the name encoding/json gives to a map key
+func mapKeyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("failed to encode map key: %w", err)
+		}
+		return string(b), nil
+	}
+
+	return fmt.Sprint(k.Interface()), nil
+}
+
+// valueKeys lists the (flattened) JSON fields of values whose type doesn't say what they encode to, sorted
+func valueKeys(values []reflect.Value, prefix string) ([]string, error) {
+	if len(values) == 0 {
+		return []string{prefix}, nil
+	}
+
+	var keys []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		fields, err := flattenJSON(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range fields {
+			if key := joinKey(prefix, f.Key); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// fieldValues flattens an item into its formatted fields, by key
+func fieldValues(item interface{}) (map[string]string, error) {
+	fields, err := flattenJSON(item)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		key := f.Key
+		if key == "" {
+			key = scalarHeader
+		}
+		values[key] = exportCell(f.Value)
+	}
+
+	return values, nil
+}
+
+// fieldColumns creates a column for every (flattened) JSON field of the items' type
+func fieldColumns(_ *TableSpec, data interface{}, _ string) ([]Column, error) {
+	_, items, err := lookup(data)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(items[0])
+	values := make([]reflect.Value, len(items))
+	for i, item := range items {
+		values[i] = reflect.ValueOf(item)
+		if values[i].Type() != t {
+			t = nil
+		}
+	}
+
+	var keys []string
+	if t != nil {
+		keys, err = fieldKeys(t, values, "")
+	} else {
+		keys, err = valueKeys(values, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]Column, len(keys))
+	for i, k := range keys {
+		key := k
+		if key == "" {
+			key = scalarHeader
+		}
+
+		// Rows are rendered by fieldRow, this is only used for --sort-by
+		columns[i] = Column{Header: key, Value: func(item interface{}) string {
+			values, err := fieldValues(item)
+			if err != nil {
+				return ""
+			}
+
+			return values[key]
+		}}
+	}
+
+	return columns, nil
+}
+
+// fieldRow renders a row of columns created by fieldColumns, flattening the item only once
+func fieldRow(item interface{}, columns []Column) []string {
+	row := make([]string, len(columns))
+	values, err := fieldValues(item)
+	if err != nil {
+		return row
+	}
+
+	for i, c := range columns {
+		row[i] = values[c.Header]
+	}
+	return row
+}
+
+// printCSV renders a table as RFC 4180 CSV
+func printCSV(w io.Writer, header []string, rows [][]string, o Options) error {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	if !o.NoHeaders {
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return nil
+}
+
+// printNDJSON prints each item (the elements of a list or the data itself) as JSON on its own line
+func printNDJSON(w io.Writer, data interface{}, _ string) error {
+	_, items, err := lookup(data)
+	if err != nil {
+		return err
+	}
+
+	e := json.NewEncoder(w)
+	for _, item := range items {
+		if err := e.Encode(item); err != nil {
+			return fmt.Errorf("failed to encode JSON: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package printer renders command output in a number of formats (selected with --output)
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Format renders data to a writer. arg is the part of the output format following "=" (e.g. the template in
+// `template=<Go template>`)
+type Format func(w io.Writer, data interface{}, arg string) error
+
+// FormatInfo describes an output format
+type FormatInfo struct {
+	// Usage is shown in the --output flag's help (the format is hidden if empty, e.g. for aliases)
+	Usage string
+	// HasArg indicates the format takes an argument (`name=<arg>`)
+	HasArg bool
+	// Tabular formats render data as a table (see Table), with Columns selecting the columns instead of Render
+	Tabular bool
+	// Columns selects the columns of a tabular format for some data (spec is nil if no table spec is registered for
+	// the data's type)
+	Columns func(spec *TableSpec, data interface{}, arg string) ([]Column, error)
+	// Row renders all of an item's cells at once (optional, the columns' values are used otherwise)
+	Row func(item interface{}, columns []Column) []string
+	// RenderTable renders the table for tabular formats (a go-pretty table in the selected style by default)
+	RenderTable func(w io.Writer, header []string, rows [][]string, o Options) error
+
+	Render Format
+}
+
+var (
+	formats     = map[string]FormatInfo{}
+	formatNames []string
+)
+
+// RegisterFormat adds an output format
+func RegisterFormat(name string, info FormatInfo) {
+	if _, ok := formats[name]; !ok {
+		formatNames = append(formatNames, name)
+	}
+
+	formats[name] = info
+}
+
+// Usage lists the available output formats (for flag help), with any extra command-specific formats first
+func Usage(extra ...string) string {
+	usages := append([]string{}, extra...)
+	for _, n := range formatNames {
+		if u := formats[n].Usage; u != "" {
+			usages = append(usages, u)
+		}
+	}
+
+	return strings.Join(usages, "|")
+}
+
+// Options represents a command's output options
+type Options struct {
+	// Format is the output format (e.g. table, json or template=<Go template>)
+	Format string
+	// Query is a jq expression used to transform the data before printing
+	Query string
+
+	// SortBy is a field (column name or JSONPath expression) to sort lists by
+	SortBy string
+	// NoHeaders omits the header row from tabular output
+	NoHeaders bool
+	// TableStyle is the style of tabular output (rounded, plain or tsv)
+	TableStyle string
+}
+
+// AddFlags adds the output options to a command, extra lists command-specific formats (handled by the command)
+func AddFlags(cmd *cobra.Command, o *Options, def string, extra ...string) {
+	cmd.Flags().StringVarP(&o.Format, "output", "o", def, fmt.Sprintf("output format `%v`", Usage(extra...)))
+	cmd.Flags().StringVar(&o.Query, "query", "", "jq `expression` to transform the output with")
+	cmd.Flags().StringVar(&o.SortBy, "sort-by", "", "sort lists by a `field` (column name or JSONPath, e.g. .id)")
+	cmd.Flags().BoolVar(&o.NoHeaders, "no-headers", false, "don't print headers in tabular output")
+	cmd.Flags().StringVar(&o.TableStyle, "table-style", StyleRounded,
+		fmt.Sprintf("`style` of tabular output (%v)", strings.Join(tableStyles, "|")))
+}
+
+func lookupFormat(outputType string) (FormatInfo, string, error) {
+	name, arg := outputType, ""
+	i := strings.Index(outputType, "=")
+	if i != -1 {
+		name, arg = outputType[:i], outputType[i+1:]
+	}
+
+	f, ok := formats[name]
+	if !ok || (i != -1 && !f.HasArg) {
+		return FormatInfo{}, "", fmt.Errorf(`unknown output format "%v"`, outputType)
+	}
+
+	return f, arg, nil
+}
+
+// Fprint renders data to a writer with the given output options. If a query is set, each of its results will be
+// rendered separately (tabular formats will print strings as-is and other values as JSON).
+func Fprint(w io.Writer, data interface{}, o Options) error {
+	f, arg, err := lookupFormat(o.Format)
+	if err != nil {
+		return err
+	}
+	if o.Query == "" {
+		if f.Tabular {
+			header, rows, err := Table(data, o.Format, o.SortBy)
+			if err != nil {
+				return err
+			}
+
+			if f.RenderTable != nil {
+				return f.RenderTable(w, header, rows, o)
+			}
+			return printTable(w, header, rows, o)
+		}
+
+		if data, err = sortData(data, o.SortBy); err != nil {
+			return err
+		}
+		return f.Render(w, data, arg)
+	}
+
+	if data, err = sortData(data, o.SortBy); err != nil {
+		return err
+	}
+	results, err := Query(data, o.Query)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if f.Tabular {
+			err = printRaw(w, r)
+		} else {
+			err = f.Render(w, r, arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Print renders data to stdout with the given output options
+func Print(data interface{}, o Options) error {
+	return Fprint(os.Stdout, data, o)
+}
+
+// sortData sorts a slice by a field (see SortBy), other data is returned as-is
+func sortData(data interface{}, field string) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	if field == "" || v.Kind() != reflect.Slice {
+		return data, nil
+	}
+
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+
+	var columns []Column
+	if spec, ok := specs[v.Type().Elem()]; ok {
+		columns = append(append(columns, spec.Columns...), spec.Wide...)
+	}
+	items, err := SortBy(items, field, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		sorted.Index(i).Set(reflect.ValueOf(item))
+	}
+
+	return sorted.Interface(), nil
+}
+
+// toJSONValue converts data to its generic JSON representation (maps, slices etc.)
+func toJSONValue(data interface{}) (interface{}, error) {
+	j, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(j, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+
+	return v, nil
+}
+
+// printRaw prints strings as-is and other values as JSON
+func printRaw(w io.Writer, v interface{}) error {
+	if s, ok := v.(string); ok {
+		_, err := fmt.Fprintln(w, s)
+		return err
+	}
+
+	return printJSON(w, v, "")
+}
+
+func printJSON(w io.Writer, data interface{}, _ string) error {
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return nil
+}
+
+func printYAML(w io.Writer, data interface{}, _ string) error {
+	if err := yaml.NewEncoder(w).Encode(data); err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+
+	return nil
+}
+
+func printTemplate(w io.Writer, data interface{}, arg string) error {
+	tpl, err := template.New("anonymous").Parse(arg)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	if err := tpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
+
+func init() {
+	RegisterFormat("table", FormatInfo{Usage: "table", Tabular: true, Columns: specColumns(false)})
+	RegisterFormat("wide", FormatInfo{Usage: "wide", Tabular: true, Columns: specColumns(true)})
+	RegisterFormat("custom-columns", FormatInfo{
+		Usage:   "custom-columns=<NAME:.path,...>",
+		HasArg:  true,
+		Tabular: true,
+		Columns: customColumns,
+	})
+	RegisterFormat("csv", FormatInfo{Usage: "csv", Tabular: true, Columns: fieldColumns, Row: fieldRow,
+		RenderTable: printCSV})
+	RegisterFormat("yaml", FormatInfo{Usage: "yaml", Render: printYAML})
+	RegisterFormat("json", FormatInfo{Usage: "json", Render: printJSON})
+	RegisterFormat("ndjson", FormatInfo{Usage: "ndjson", Render: printNDJSON})
+	RegisterFormat("jsonpath", FormatInfo{Usage: "jsonpath=<JSONPath>", HasArg: true, Render: printJSONPath})
+	RegisterFormat("template", FormatInfo{Usage: "template=<Go template>", HasArg: true, Render: printTemplate})
+
+	// Aliases for compatibility
+	RegisterFormat("table-wide", FormatInfo{Tabular: true, Columns: specColumns(true)})
+	RegisterFormat("text", FormatInfo{Tabular: true, Columns: specColumns(false)})
+}
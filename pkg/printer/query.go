@@ -0,0 +1,89 @@
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/itchyny/gojq"
+)
+
+// Query runs a jq expression on data, returning all of its results
+func Query(data interface{}, query string) ([]interface{}, error) {
+	q, err := gojq.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+
+	v, err := toJSONValue(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []interface{}
+	iter := q.Run(v)
+	for {
+		r, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := r.(error); ok {
+			return nil, fmt.Errorf("failed to run query: %w", err)
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// normalizeJSONPath accepts kubectl-style (`{.items[0]}`) and relative (`.items[0]`) expressions as well as standard
+// JSONPath (`$.items[0]`)
+func normalizeJSONPath(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+
+	switch {
+	case strings.HasPrefix(expr, "$"):
+	case strings.HasPrefix(expr, ".") || strings.HasPrefix(expr, "["):
+		expr = "$" + expr
+	default:
+		expr = "$." + expr
+	}
+
+	// kubectl allows a dot before an index (e.g. `.[0]`)
+	return strings.ReplaceAll(expr, ".[", "[")
+}
+
+// printJSONPath evaluates a JSONPath expression, printing each result on its own line (strings as-is and other
+// values as JSON)
+func printJSONPath(w io.Writer, data interface{}, arg string) error {
+	v, err := toJSONValue(data)
+	if err != nil {
+		return err
+	}
+
+	expr := normalizeJSONPath(arg)
+	result, err := jsonpath.Get(expr, v)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate JSONPath: %w", err)
+	}
+
+	// Wildcards, unions and filters return a list of matches
+	if strings.ContainsAny(expr, "*,?:") || strings.Contains(expr, "..") {
+		if results, ok := result.([]interface{}); ok {
+			for _, r := range results {
+				if err := printRaw(w, r); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	return printRaw(w, result)
+}
@@ -0,0 +1,415 @@
+package printer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Column describes a table column
+type Column struct {
+	Header string
+	// Value formats the column's value for a single item
+	Value func(item interface{}) string
+	// Delta optionally describes the change in the column's value between two versions of an item (e.g. "+1 MiB"),
+	// shown by --watch. An empty string means there's no change worth showing.
+	Delta func(prev, cur interface{}) string
+}
+
+// TableSpec describes how a resource is rendered as a table
+type TableSpec struct {
+	// Rows splits data into items (one per row). By default, the elements of a slice are used (or the data itself
+	// for a single object).
+	Rows func(data interface{}) []interface{}
+	// Key identifies an item (e.g. by ID), used by --watch to match up rows between updates. Rows are matched by
+	// position if not set.
+	Key func(item interface{}) string
+
+	Columns []Column
+	// Wide is the full set of columns used for wide output (Columns is used if not set)
+	Wide []Column
+}
+
+// YesNo formats a boolean for a table cell
+func YesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+var specs = map[reflect.Type]*TableSpec{}
+
+// Register sets the table spec for the type of v (which should be a single object, slices of the type will also
+// be rendered using the spec)
+func Register(v interface{}, spec TableSpec) {
+	specs[reflect.TypeOf(v)] = &spec
+}
+
+// lookup finds the table spec for some data (nil if none is registered) and splits it into items
+func lookup(data interface{}) (*TableSpec, []interface{}, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil, fmt.Errorf("table output is not supported for %T", data)
+	}
+
+	if spec, ok := specs[v.Type()]; ok {
+		if spec.Rows != nil {
+			return spec, spec.Rows(v.Interface()), nil
+		}
+
+		return spec, []interface{}{v.Interface()}, nil
+	}
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).Interface()
+		}
+
+		return specs[v.Type().Elem()], items, nil
+	}
+
+	return nil, []interface{}{v.Interface()}, nil
+}
+
+// specColumns selects the columns of a registered table spec
+func specColumns(wide bool) func(spec *TableSpec, data interface{}, _ string) ([]Column, error) {
+	return func(spec *TableSpec, data interface{}, _ string) ([]Column, error) {
+		if spec == nil {
+			return nil, fmt.Errorf("table output is not supported for %T", data)
+		}
+
+		if wide && spec.Wide != nil {
+			return spec.Wide, nil
+		}
+		return spec.Columns, nil
+	}
+}
+
+// customColumns parses a kubectl-style column list (`NAME:.path,...`), with each value being a JSONPath expression
+// evaluated against an item
+func customColumns(_ *TableSpec, _ interface{}, arg string) ([]Column, error) {
+	if strings.TrimSpace(arg) == "" {
+		return nil, errors.New("custom-columns requires at least one column (NAME:.path)")
+	}
+
+	var columns []Column
+	for _, c := range strings.Split(arg, ",") {
+		i := strings.Index(c, ":")
+		if i <= 0 || i == len(c)-1 {
+			return nil, fmt.Errorf(`invalid custom column "%v" (expected NAME:.path)`, c)
+		}
+
+		expr := normalizeJSONPath(c[i+1:])
+		eval, err := jsonpath.New(expr)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse JSONPath for column "%v": %w`, c[:i], err)
+		}
+
+		columns = append(columns, Column{Header: c[:i], Value: func(item interface{}) string {
+			v, err := toJSONValue(item)
+			if err != nil {
+				return "<error>"
+			}
+
+			// Missing fields are left empty
+			r, err := eval(context.Background(), v)
+			if err != nil || r == nil {
+				return ""
+			}
+
+			return cellString(r)
+		}})
+	}
+
+	return columns, nil
+}
+
+// cellString formats a generic JSON value for a table cell (strings as-is, lists one per line and other values as
+// JSON)
+func cellString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []interface{}:
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = cellString(e)
+		}
+		return strings.Join(s, "\n")
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(j)
+}
+
+// Table converts data to a table header and rows using the columns selected by a tabular output format, sorting rows
+// by the field sortBy (see SortBy)
+func Table(data interface{}, format, sortBy string) ([]string, [][]string, error) {
+	t, err := newTable(data, format, sortBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t.Header, t.Rows, nil
+}
+
+// tableData is a table along with the columns and items it was created from
+type tableData struct {
+	Columns []Column
+	Items   []interface{}
+	Key     func(item interface{}) string
+
+	Header []string
+	Rows   [][]string
+}
+
+func newTable(data interface{}, format, sortBy string) (*tableData, error) {
+	f, arg, err := lookupFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if !f.Tabular {
+		return nil, fmt.Errorf(`output format "%v" is not tabular`, format)
+	}
+
+	spec, items, err := lookup(data)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := f.Columns(spec, data, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if sortBy != "" {
+		var all []Column
+		if spec != nil {
+			all = append(all, spec.Columns...)
+			all = append(all, spec.Wide...)
+		}
+		if items, err = SortBy(items, sortBy, append(columns, all...)); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &tableData{
+		Columns: columns,
+		Items:   items,
+		Header:  make([]string, len(columns)),
+		Rows:    make([][]string, len(items)),
+	}
+	if spec != nil {
+		t.Key = spec.Key
+	}
+	for i, c := range columns {
+		t.Header[i] = c.Header
+	}
+	for i, item := range items {
+		if f.Row != nil {
+			t.Rows[i] = f.Row(item, columns)
+			continue
+		}
+
+		t.Rows[i] = make([]string, len(columns))
+		for j, c := range columns {
+			t.Rows[i][j] = c.Value(item)
+		}
+	}
+
+	return t, nil
+}
+
+// SortBy sorts items by a field, which is either a JSONPath expression (e.g. `.meta.created`) or the header of one of
+// columns (case-insensitive). Numbers are compared numerically and everything else as strings.
+func SortBy(items []interface{}, field string, columns []Column) ([]interface{}, error) {
+	key, err := sortKey(field, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]interface{}, len(items))
+	for i, item := range items {
+		if keys[i], err = key(item); err != nil {
+			return nil, err
+		}
+	}
+
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return lessValue(keys[idx[i]], keys[idx[j]])
+	})
+
+	sorted := make([]interface{}, len(items))
+	for i, j := range idx {
+		sorted[i] = items[j]
+	}
+
+	return sorted, nil
+}
+
+func sortKey(field string, columns []Column) (func(item interface{}) (interface{}, error), error) {
+	if strings.ContainsAny(field[:1], ".[{$") {
+		eval, err := jsonpath.New(normalizeJSONPath(field))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse --sort-by JSONPath: %w", err)
+		}
+
+		return func(item interface{}) (interface{}, error) {
+			v, err := toJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			// Missing fields sort first
+			r, err := eval(context.Background(), v)
+			if err != nil {
+				return nil, nil
+			}
+			return r, nil
+		}, nil
+	}
+
+	for _, c := range columns {
+		if strings.EqualFold(c.Header, field) {
+			value := c.Value
+			return func(item interface{}) (interface{}, error) {
+				s := value(item)
+				if n, err := strconv.ParseFloat(s, 64); err == nil {
+					return n, nil
+				}
+				return s, nil
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf(`unknown --sort-by field "%v" (use a column name or a JSONPath expression like .id)`, field)
+}
+
+func lessValue(a, b interface{}) bool {
+	switch {
+	case a == nil:
+		return b != nil
+	case b == nil:
+		return false
+	}
+
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			return x < y
+		}
+	}
+
+	return cellString(a) < cellString(b)
+}
+
+// Table styles (selected with --table-style)
+const (
+	StyleRounded = "rounded"
+	StylePlain   = "plain"
+	StyleTSV     = "tsv"
+)
+
+var tableStyles = []string{StyleRounded, StylePlain, StyleTSV}
+
+// plainStyle aligns columns with spaces and draws no borders, for use with tools like awk
+var plainStyle = func() table.Style {
+	s := table.StyleDefault
+	s.Name = "StylePlain"
+	s.Box.PaddingLeft = ""
+	s.Box.PaddingRight = "   "
+	s.Options = table.Options{}
+	return s
+}()
+
+// printTable renders a table in the selected style. Multi-line cells are joined with " / " in the plain and tsv
+// styles, so each row is on a single line.
+func printTable(w io.Writer, header []string, rows [][]string, o Options) error {
+	switch o.TableStyle {
+	case StyleTSV:
+		tsvCell := strings.NewReplacer("\t", " ", "\n", " / ")
+		line := func(cells []string) string {
+			c := make([]string, len(cells))
+			for i := range cells {
+				c[i] = tsvCell.Replace(cells[i])
+			}
+			return strings.Join(c, "\t")
+		}
+
+		if !o.NoHeaders {
+			fmt.Fprintln(w, line(header))
+		}
+		for _, r := range rows {
+			fmt.Fprintln(w, line(r))
+		}
+
+		return nil
+	case "", StyleRounded, StylePlain:
+	default:
+		return fmt.Errorf(`unknown table style "%v" (expected one of %v)`, o.TableStyle,
+			strings.Join(tableStyles, ", "))
+	}
+
+	t := table.NewWriter()
+	if o.TableStyle == StylePlain {
+		t.SetStyle(plainStyle)
+	} else {
+		t.SetStyle(table.StyleRounded)
+	}
+
+	if !o.NoHeaders {
+		h := make(table.Row, len(header))
+		for i, c := range header {
+			h[i] = c
+		}
+		t.AppendHeader(h)
+	}
+
+	for _, r := range rows {
+		row := make(table.Row, len(r))
+		for i, c := range r {
+			if o.TableStyle == StylePlain {
+				c = strings.ReplaceAll(c, "\n", " / ")
+			}
+			row[i] = c
+
+			// Make rows with multi-line cells easier to tell apart
+			if strings.Contains(c, "\n") {
+				t.Style().Options.SeparateRows = true
+			}
+		}
+		t.AppendRow(row)
+	}
+
+	out := t.Render()
+	if o.TableStyle == StylePlain {
+		lines := strings.Split(out, "\n")
+		for i := range lines {
+			lines[i] = strings.TrimRight(lines[i], " ")
+		}
+		out = strings.Join(lines, "\n")
+	}
+
+	fmt.Fprintln(w, out)
+	return nil
+}
@@ -0,0 +1,222 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/mattn/go-isatty"
+)
+
+// WatchOptions represents a command's --watch options
+type WatchOptions struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+var highlight = text.Colors{text.Bold, text.FgYellow}
+
+// highlightLines highlights each line of s separately (so multi-line table cells render correctly)
+func highlightLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = highlight.Sprint(l)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Watch calls fetch repeatedly (every w.Interval) and prints the result. If stdout is a terminal, the output is
+// redrawn in place (with changes highlighted in tabular formats). Otherwise, new and changed objects are printed as
+// ndjson. Watch only returns if the first fetch fails.
+func Watch(fetch func() (interface{}, error), o Options, w WatchOptions) error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("invalid watch interval %v", w.Interval)
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return err
+	}
+
+	var update func(data interface{}, err error) error
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		update = redrawer(o, w)
+	} else {
+		update = changeEmitter(os.Stdout, o)
+	}
+	if err := update(data, nil); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for range t.C {
+		data, err := fetch()
+		if err := update(data, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redrawer clears the terminal and prints the latest data along with a status line
+func redrawer(o Options, w WatchOptions) func(data interface{}, err error) error {
+	var (
+		prev     *tableData
+		lastData interface{}
+	)
+	return func(data interface{}, fetchErr error) error {
+		status := fmt.Sprintf("Every %v: %v", w.Interval, time.Now().Format(time.RFC1123))
+		if fetchErr != nil {
+			// Keep showing the last data we got
+			status += highlight.Sprintf(" (update failed: %v)", fetchErr)
+			data = lastData
+		}
+		lastData = data
+
+		var buf bytes.Buffer
+		f, _, err := lookupFormat(o.Format)
+		if err != nil {
+			return err
+		}
+		if f.Tabular && f.RenderTable == nil && o.Query == "" {
+			t, err := newTable(data, o.Format, o.SortBy)
+			if err != nil {
+				return err
+			}
+
+			header, rows := t.Header, t.Rows
+			if prev != nil && fetchErr == nil {
+				rows = highlightChanges(prev, t)
+			}
+			if fetchErr == nil {
+				prev = t
+			}
+
+			if err := printTable(&buf, header, rows, o); err != nil {
+				return err
+			}
+		} else if err := Fprint(&buf, data, o); err != nil {
+			return err
+		}
+
+		// Move the cursor to the top left and clear the screen
+		fmt.Print("\x1b[H\x1b[2J")
+		fmt.Println(status)
+		fmt.Println()
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+}
+
+// highlightChanges returns the rows of cur with cells that changed since prev highlighted (along with any deltas
+// the columns describe). Rows are matched by the table's key (or by position if it has none) and new rows are
+// highlighted entirely.
+func highlightChanges(prev, cur *tableData) [][]string {
+	var prevIndex map[string]int
+	if cur.Key != nil {
+		prevIndex = make(map[string]int, len(prev.Items))
+		for i, item := range prev.Items {
+			prevIndex[cur.Key(item)] = i
+		}
+	}
+
+	rows := make([][]string, len(cur.Rows))
+	for i, r := range cur.Rows {
+		rows[i] = append([]string{}, r...)
+
+		p, ok := i, i < len(prev.Rows)
+		if prevIndex != nil {
+			p, ok = prevIndex[cur.Key(cur.Items[i])]
+		}
+		if !ok {
+			for j := range r {
+				rows[i][j] = highlightLines(r[j])
+			}
+			continue
+		}
+
+		for j, c := range cur.Columns {
+			if j >= len(prev.Rows[p]) {
+				break
+			}
+
+			if c.Delta != nil {
+				if d := c.Delta(prev.Items[p], cur.Items[i]); d != "" {
+					sep := " "
+					if strings.Contains(r[j], "\n") {
+						sep = "\n"
+					}
+					rows[i][j] = r[j] + sep + highlightLines(d)
+					continue
+				}
+			}
+			if r[j] != prev.Rows[p][j] {
+				rows[i][j] = highlightLines(r[j])
+			}
+		}
+	}
+
+	return rows
+}
+
+// changeEmitter prints new and changed objects (the items of the data, or the results of the query) as ndjson
+func changeEmitter(w io.Writer, o Options) func(data interface{}, err error) error {
+	seen := map[string]bool{}
+	return func(data interface{}, fetchErr error) error {
+		if fetchErr != nil {
+			log.Printf("Update failed: %v", fetchErr)
+			return nil
+		}
+
+		var items []interface{}
+		var err error
+		if o.Query != "" {
+			if data, err = sortData(data, o.SortBy); err != nil {
+				return err
+			}
+			items, err = Query(data, o.Query)
+		} else {
+			var spec *TableSpec
+			spec, items, err = lookup(data)
+			if err == nil && o.SortBy != "" {
+				var columns []Column
+				if spec != nil {
+					columns = append(append(columns, spec.Columns...), spec.Wide...)
+				}
+				items, err = SortBy(items, o.SortBy, columns)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		current := make(map[string]bool, len(items))
+		for _, item := range items {
+			j, err := json.Marshal(item)
+			if err != nil {
+				return fmt.Errorf("failed to encode JSON: %w", err)
+			}
+
+			current[string(j)] = true
+			if !seen[string(j)] {
+				if _, err := fmt.Fprintln(w, string(j)); err != nil {
+					return err
+				}
+			}
+		}
+		seen = current
+
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// ErrNotLoggedIn indicates that there is no token for the selected profile
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// UnauthorizedError indicates that an API rejected the token used for a request
+type UnauthorizedError struct {
+	Message string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Message
+}
+
+// TokenExpiredError indicates that the selected profile's token has expired
+type TokenExpiredError struct {
+	Profile   string
+	ExpiredAt time.Time
+}
+
+func (e *TokenExpiredError) Error() string {
+	return fmt.Sprintf("token for profile %v expired at %v, run `netsoc account login` to log in again",
+		e.Profile, e.ExpiredAt.Local().Format(TableDateFormat))
+}
+
+// authTransport retries requests rejected with 401 Unauthorized once with a new token if reauth (which asks the user
+// to log in again) succeeds. Only the rejected request is retried, so anything done before it isn't repeated.
+type authTransport struct {
+	base   http.RoundTripper
+	reauth func(message string, rejected string) (string, bool)
+
+	mutex     sync.Mutex
+	succeeded int
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	auth := req.Header.Get("Authorization")
+	if res.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(auth, "Bearer ") ||
+		(req.Body != nil && req.GetBody == nil) {
+		if res.StatusCode != http.StatusUnauthorized {
+			t.mutex.Lock()
+			t.succeeded++
+			t.mutex.Unlock()
+		}
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var e struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &e)
+	if e.Message == "" {
+		e.Message = res.Status
+	}
+
+	token, ok := t.reauth(e.Message, strings.TrimPrefix(auth, "Bearer "))
+	if !ok {
+		return res, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+
+	Debugf("Retrying %v %v with new token", req.Method, req.URL)
+	res, err = t.base.RoundTrip(retry)
+	if err == nil && res.StatusCode != http.StatusUnauthorized {
+		t.mutex.Lock()
+		t.succeeded++
+		t.mutex.Unlock()
+	}
+
+	return res, err
+}
+
+// Succeeded returns the number of requests which haven't been rejected as unauthorized
+func (t *authTransport) Succeeded() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.succeeded
+}
+
+// ParseClaims decodes the claims in a token (without verifying its signature)
+func ParseClaims(token string) (*UserClaims, error) {
+	t, _, err := jwt.NewParser().ParseUnverified(token, &UserClaims{})
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Claims.(*UserClaims), nil
+}
+
+// Prompt asks for a line of input on the command line
+func Prompt(prompt string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%v: ", prompt)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read failed: %w", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
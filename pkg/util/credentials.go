@@ -0,0 +1,53 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
+)
+
+// newCredentialStore creates the credential store selected in the config
+func newCredentialStore(c *config.Config, configFile func() (*config.File, error), configPath string) (credentials.Store, error) {
+	opts := c.CredentialStore
+	switch opts.Type {
+	case "", config.CredentialStoreConfig:
+		return &credentials.ConfigStore{File: configFile}, nil
+	case config.CredentialStoreEncrypted:
+		path := opts.File
+		if path == "" {
+			path = filepath.Join(filepath.Dir(configPath), ".netsoc-credentials")
+		}
+
+		return &credentials.EncryptedFileStore{
+			Path:       path,
+			KeyFile:    opts.KeyFile,
+			Passphrase: credentialsPassphrase,
+		}, nil
+	case config.CredentialStorePass:
+		return &credentials.PassStore{Prefix: opts.PassPrefix}, nil
+	case config.CredentialStoreHelper:
+		if opts.Helper == "" {
+			return nil, errors.New("credential_store.helper must be set to use a credential helper")
+		}
+
+		return &credentials.HelperStore{Helper: opts.Helper}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential store type %q", opts.Type)
+	}
+}
+
+func credentialsPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv("NETSOC_CREDENTIALS_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+
+	if !IsInteractive() {
+		return "", errors.New("not running interactively (set NETSOC_CREDENTIALS_PASSPHRASE)")
+	}
+
+	return PromptSecret("Credential store passphrase", confirm)
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	webspaced "github.com/netsoc/webspaced/client"
+
+	"github.com/netsoc/cli/pkg/config"
+)
+
+// execStreamReady is printed by the remote side once the PTY is in raw mode
+const execStreamReady = "NETSOC-EXEC-STREAM-READY"
+
+// ExecStream is a binary-safe stream to a shell script running in a webspace.
+//
+// The interactive exec websocket is connected to a PTY, so the PTY is put in raw mode (no echo, line editing or
+// newline translation) before the script runs. Since there's no way to close the remote side's stdin, scripts must
+// know how much input to read (e.g. with `head -c`). Output on stderr is mixed with stdout, so scripts should
+// redirect it if their output is parsed.
+type ExecStream struct {
+	rw *WebsocketIO
+	r  *bufio.Reader
+
+	exitCode int
+	exited   bool
+}
+
+// StartExecStream runs a shell script (with args as its positional parameters) in a webspace
+func StartExecStream(c *config.Config, token, user, script string, args ...string) (*ExecStream, error) {
+	conn, err := WebspacedWebsocket(c, token, user, "exec")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open websocket connection: %w", err)
+	}
+
+	command := append([]string{
+		"sh", "-c",
+		"stty raw -echo -iexten 2>/dev/null || exit 126; printf '%s\\n' " + execStreamReady + "; " + script,
+		"sh",
+	}, args...)
+	if err := conn.WriteJSON(webspaced.ExecInteractiveRequest{
+		Command: command,
+		Width:   80,
+		Height:  24,
+	}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send exec request: %w", err)
+	}
+
+	s := &ExecStream{
+		rw: NewWebsocketIO(conn, func(s string, _ *WebsocketIO) {
+			Debugf("Received websocket text message: %v", s)
+		}),
+	}
+	s.r = bufio.NewReader(readerFunc(s.read))
+
+	// Anything before the marker was sent before the PTY was in raw mode
+	for {
+		line, err := s.r.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == execStreamReady {
+			break
+		}
+
+		if err != nil {
+			s.rw.Close()
+			if err == io.EOF && s.exitCode == 126 {
+				return nil, errors.New("failed to put remote terminal in raw mode (is stty installed?)")
+			}
+			if err == io.EOF {
+				return nil, fmt.Errorf("remote command exited early with code %v", s.exitCode)
+			}
+
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// read reads from the websocket, returning io.EOF (and recording the exit code) once the remote command exits
+func (s *ExecStream) read(p []byte) (int, error) {
+	if s.exited {
+		return 0, io.EOF
+	}
+
+	n, err := s.rw.Read(p)
+	if n < 0 {
+		n = 0
+	}
+
+	var ce *websocket.CloseError
+	switch {
+	case errors.As(err, &ce) && ce.Code == websocket.CloseNormalClosure:
+		s.exited = true
+		if s.exitCode, err = strconv.Atoi(ce.Text); err != nil {
+			return n, fmt.Errorf("failed to parse exit code: %w", err)
+		}
+
+		return n, io.EOF
+	case err == io.EOF:
+		s.exited = true
+		s.exitCode = -1
+	}
+
+	return n, err
+}
+
+// Read reads the script's output
+func (s *ExecStream) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// ReadLine reads a line of output (without the line ending)
+func (s *ExecStream) ReadLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Write writes to the script's stdin
+func (s *ExecStream) Write(p []byte) (int, error) {
+	return s.rw.Write(p)
+}
+
+// Wait discards any remaining output and returns the script's exit code
+func (s *ExecStream) Wait() (int, error) {
+	if _, err := io.Copy(ioutil.Discard, s.r); err != nil {
+		return -1, err
+	}
+
+	return s.exitCode, nil
+}
+
+// Output reads all of the remaining output and returns it along with the script's exit code
+func (s *ExecStream) Output() (string, int, error) {
+	out, err := ioutil.ReadAll(s.r)
+	if err != nil {
+		return "", -1, err
+	}
+
+	return string(out), s.exitCode, nil
+}
+
+// Close closes the websocket connection (killing the script if it's still running)
+func (s *ExecStream) Close() error {
+	return s.rw.Close()
+}
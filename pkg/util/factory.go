@@ -1,18 +1,23 @@
 package util
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/netsoc/cli/pkg/config"
+	"github.com/netsoc/cli/pkg/credentials"
 	iam "github.com/netsoc/iam/client"
 	webspaced "github.com/netsoc/webspaced/client"
 )
@@ -20,24 +25,75 @@ import (
 // CmdFactory provides methods to obtain commonly used structures
 type CmdFactory struct {
 	Config          func() (*config.Config, error)
+	ConfigPath      func() string
+	ConfigFile      func() (*config.File, error)
+	ConfigSource    func(key string) (config.Source, error)
+	CredentialStore func() (credentials.Store, error)
+	// Token returns a valid token for the selected profile, offering to log in again (when interactive) if it has
+	// expired
+	Token func() (string, error)
+	// Login runs the password login flow for a user and stores the new token for the selected profile
+	Login func(username string) (string, error)
+	// Reauthenticate offers to log in again if a command failed because its token was rejected, returning true if the
+	// command should be retried. API requests are retried individually when their token is rejected, so this only
+	// applies if nothing else was done before the failure (e.g. a websocket being rejected).
+	Reauthenticate  func(err error) (bool, error)
 	Claims          func() (*UserClaims, error)
 	IAMClient       func() (*iam.APIClient, error)
 	WebspacedClient func() (*webspaced.APIClient, error)
 }
 
 // NewDefaultCmdFactory creates a new command factory
-func NewDefaultCmdFactory(configFlag, debugFlag *pflag.Flag) *CmdFactory {
+func NewDefaultCmdFactory(configFlag, debugFlag, profileFlag *pflag.Flag) *CmdFactory {
+	configPath := func() string {
+		configFile := os.Getenv("NETSOC_CONFIG")
+		if configFile == "" || configFlag.Changed {
+			configFile = configFlag.Value.String()
+		}
+
+		return configFile
+	}
+
+	configFile := func() (*config.File, error) {
+		return config.ReadFile(configPath())
+	}
+
 	var cachedConfig *config.Config
-	configFunc := func() (*config.Config, error) {
+	var cachedStore credentials.Store
+	var cachedToken *string
+	var configFunc func() (*config.Config, error)
+	credentialStore := func() (credentials.Store, error) {
+		if cachedStore != nil {
+			return cachedStore, nil
+		}
+
+		c, err := configFunc()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+
+		cachedStore, err = newCredentialStore(c, configFile, configPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up credential store: %w", err)
+		}
+
+		return cachedStore, nil
+	}
+
+	configFunc = func() (*config.Config, error) {
 		if cachedConfig != nil {
 			return cachedConfig, nil
 		}
 
 		config.SetDefaults()
 
-		configFile := os.Getenv("NETSOC_CONFIG")
-		if configFile == "" || configFlag.Changed {
-			configFile = configFlag.Value.String()
+		configFile := configPath()
+		if f, err := config.ReadFile(configFile); err == nil && f.Migrate() {
+			if err := f.Write(); err != nil {
+				return nil, fmt.Errorf("failed to migrate config file: %w", err)
+			}
+
+			log.Printf("Migrated config file %v to use profiles", configFile)
 		}
 		viper.SetConfigFile(configFile)
 
@@ -62,48 +118,293 @@ func NewDefaultCmdFactory(configFlag, debugFlag *pflag.Flag) *CmdFactory {
 			Debugf("Loaded config file: %v", viper.ConfigFileUsed())
 		}
 
-		if err := viper.Unmarshal(&cachedConfig, config.DecoderOptions); err != nil {
+		// Select profile
+		profile := viper.GetString("current_profile")
+		if p := os.Getenv("NETSOC_PROFILE"); p != "" {
+			profile = p
+		}
+		if profileFlag.Changed {
+			profile = profileFlag.Value.String()
+		}
+		if !config.ValidProfileName(profile) {
+			return nil, fmt.Errorf("invalid profile name %q", profile)
+		}
+		if profile != config.DefaultProfile && !viper.IsSet("profiles."+profile) {
+			return nil, fmt.Errorf("profile %q does not exist", profile)
+		}
+		Debugf("Using profile: %v", profile)
+
+		config.SetProfileDefaults(profile)
+		for _, k := range config.ProfileKeys() {
+			key := "profiles." + profile + "." + k
+			viper.BindEnv(append([]string{key}, config.EnvVars(key, profile)...)...)
+		}
+
+		var c *config.Config
+		if err := viper.Unmarshal(&c, config.DecoderOptions); err != nil {
 			return nil, fmt.Errorf("failed to parse configuration: %w", err)
 		}
+		c.ProfileName = profile
+		c.Profile = c.Profiles[profile]
+
+		c.Token = func() (string, error) {
+			if cachedToken != nil {
+				return *cachedToken, nil
+			}
+
+			t, err := credentials.Token(credentialStore, profile, c.Profile.Token)
+			if err != nil {
+				return "", err
+			}
+
+			cachedToken = &t
+			return t, nil
+		}
 
+		cachedConfig = c
 		return cachedConfig, nil
 	}
 
+	// API requests rejected because of the token are retried (once) after logging in again
+	var (
+		relogin      func(reason string) (bool, error)
+		transport    *authTransport
+		reauthMutex  sync.Mutex
+		reauthFailed bool
+	)
+	reauth := func(message, rejected string) (string, bool) {
+		reauthMutex.Lock()
+		defer reauthMutex.Unlock()
+
+		if reauthFailed || !IsInteractive() {
+			return "", false
+		}
+		if cachedToken != nil && *cachedToken != rejected {
+			// Already logged in again (for a concurrent request)
+			return *cachedToken, true
+		}
+
+		ok, err := relogin(fmt.Sprintf("Token rejected: %v", message))
+		if err != nil {
+			log.Printf("Failed to log in again: %v", err)
+		}
+		if err != nil || !ok {
+			reauthFailed = true
+			return "", false
+		}
+
+		return *cachedToken, true
+	}
+	httpClient := func(c *config.Config) *http.Client {
+		if transport == nil {
+			base := http.DefaultTransport
+			if c.AllowInsecure {
+				base = &http.Transport{
+					TLSClientConfig: &tls.Config{
+						InsecureSkipVerify: true,
+					},
+				}
+			}
+
+			transport = &authTransport{base: base, reauth: reauth}
+		}
+
+		return &http.Client{Transport: transport}
+	}
+
+	iamClient := func() (*iam.APIClient, error) {
+		c, err := configFunc()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+
+		cfg := iam.NewConfiguration()
+		cfg.BasePath = c.URLs.IAM
+		cfg.HTTPClient = httpClient(c)
+
+		return iam.NewAPIClient(cfg), nil
+	}
+
+	login := func(username string) (string, error) {
+		c, err := configFunc()
+		if err != nil {
+			return "", fmt.Errorf("failed to load config: %w", err)
+		}
+		client, err := iamClient()
+		if err != nil {
+			return "", err
+		}
+
+		p, err := ReadPassword(false)
+		if err != nil {
+			return "", fmt.Errorf("failed to get password: %w", err)
+		}
+
+		t, _, err := client.UsersApi.Login(context.Background(), username, iam.LoginRequest{Password: p})
+		if err != nil {
+			return "", APIError(err)
+		}
+
+		store, err := credentialStore()
+		if err != nil {
+			return "", err
+		}
+		if err := credentials.StoreToken(store, configFile, c.ProfileName, t.Token); err != nil {
+			return "", fmt.Errorf("failed to store token: %w", err)
+		}
+
+		cachedToken = &t.Token
+		return t.Token, nil
+	}
+
+	// relogin asks the user to log in again as the selected profile's user
+	relogin = func(reason string) (bool, error) {
+		c, err := configFunc()
+		if err != nil {
+			return false, fmt.Errorf("failed to load config: %w", err)
+		}
+
+		log.Print(reason)
+		again, err := YesNo("Log in again?", true)
+		if err != nil || !again {
+			return false, err
+		}
+
+		username := c.User
+		if username == "" {
+			if username, err = Prompt("Username"); err != nil {
+				return false, err
+			}
+		}
+
+		if _, err := login(username); err != nil {
+			return false, err
+		}
+
+		log.Println("Logged in successfully")
+		return true, nil
+	}
+
+	var tokenUsed, expiryWarned bool
+	token := func() (string, error) {
+		c, err := configFunc()
+		if err != nil {
+			return "", fmt.Errorf("failed to load config: %w", err)
+		}
+
+		t, err := c.Token()
+		if err != nil {
+			return "", err
+		}
+		if t == "" {
+			return "", ErrNotLoggedIn
+		}
+
+		claims, err := ParseClaims(t)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse token: %w", err)
+		}
+		if claims.ExpiresAt == nil {
+			tokenUsed = true
+			return t, nil
+		}
+
+		expiry := claims.ExpiresAt.Time
+		switch {
+		case time.Now().After(expiry):
+			expiredErr := &TokenExpiredError{Profile: c.ProfileName, ExpiredAt: expiry}
+			if !IsInteractive() {
+				return "", expiredErr
+			}
+
+			ok, err := relogin(fmt.Sprintf("Token for profile %v has expired", c.ProfileName))
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "", expiredErr
+			}
+
+			t = *cachedToken
+		case time.Until(expiry) < c.TokenExpiryWarning && !expiryWarned:
+			log.Printf("Warning: token for profile %v expires %v, run `netsoc account login --force` to renew it",
+				c.ProfileName, humanize.Time(expiry))
+			expiryWarned = true
+		}
+
+		tokenUsed = true
+		return t, nil
+	}
+
 	return &CmdFactory{
-		Config: configFunc,
-		Claims: func() (*UserClaims, error) {
+		Config:          configFunc,
+		ConfigPath:      configPath,
+		CredentialStore: credentialStore,
+		Token:           token,
+		Login:           login,
+		Reauthenticate: func(err error) (bool, error) {
+			var authErr *UnauthorizedError
+			if !tokenUsed || !errors.As(err, &authErr) || !IsInteractive() {
+				return false, nil
+			}
+
+			reauthMutex.Lock()
+			defer reauthMutex.Unlock()
+			if reauthFailed || (transport != nil && transport.Succeeded() != 0) {
+				// Re-running the command could repeat something which already happened
+				return false, nil
+			}
+
+			return relogin(fmt.Sprintf("Token rejected: %v", authErr.Message))
+		},
+		ConfigFile: configFile,
+		ConfigSource: func(key string) (config.Source, error) {
 			c, err := configFunc()
 			if err != nil {
-				return nil, fmt.Errorf("failed to load config: %w", err)
+				return config.Source{}, fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := config.CheckKey(key); err != nil {
+				return config.Source{}, err
+			}
+
+			key = strings.ToLower(key)
+			switch {
+			case key == "debug" && debugFlag.Changed:
+				return config.Source{Kind: config.SourceFlag, Name: debugFlag.Name}, nil
+			case key == "current_profile" && profileFlag.Changed:
+				return config.Source{Kind: config.SourceFlag, Name: profileFlag.Name}, nil
 			}
 
-			t, _, err := jwt.NewParser().ParseUnverified(c.Token, &UserClaims{})
+			for _, e := range config.EnvVars(key, c.ProfileName) {
+				if _, ok := os.LookupEnv(e); ok {
+					return config.Source{Kind: config.SourceEnv, Name: e}, nil
+				}
+			}
+
+			f, err := config.ReadFile(configPath())
 			if err != nil {
-				return nil, err
+				return config.Source{}, err
+			}
+			if _, ok := f.Get(key); ok {
+				return config.Source{Kind: config.SourceFile}, nil
 			}
 
-			return t.Claims.(*UserClaims), nil
+			return config.Source{Kind: config.SourceDefault}, nil
 		},
-		IAMClient: func() (*iam.APIClient, error) {
+		Claims: func() (*UserClaims, error) {
 			c, err := configFunc()
 			if err != nil {
 				return nil, fmt.Errorf("failed to load config: %w", err)
 			}
 
-			cfg := iam.NewConfiguration()
-			cfg.BasePath = c.URLs.IAM
-			if c.AllowInsecure {
-				cfg.HTTPClient = &http.Client{
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{
-							InsecureSkipVerify: true,
-						},
-					},
-				}
+			token, err := c.Token()
+			if err != nil {
+				return nil, err
 			}
 
-			return iam.NewAPIClient(cfg), nil
+			return ParseClaims(token)
 		},
+		IAMClient: iamClient,
 		WebspacedClient: func() (*webspaced.APIClient, error) {
 			c, err := configFunc()
 			if err != nil {
@@ -112,15 +413,7 @@ func NewDefaultCmdFactory(configFlag, debugFlag *pflag.Flag) *CmdFactory {
 
 			cfg := webspaced.NewConfiguration()
 			cfg.BasePath = c.URLs.Webspaced
-			if c.AllowInsecure {
-				cfg.HTTPClient = &http.Client{
-					Transport: &http.Transport{
-						TLSClientConfig: &tls.Config{
-							InsecureSkipVerify: true,
-						},
-					},
-				}
-			}
+			cfg.HTTPClient = httpClient(c)
 
 			return webspaced.NewAPIClient(cfg), nil
 		},
@@ -0,0 +1,230 @@
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// RecordingEventOutput is an asciicast event for data written to the terminal
+	RecordingEventOutput = "o"
+	// RecordingEventResize is an asciicast event for the terminal being resized (data is "WIDTHxHEIGHT")
+	RecordingEventResize = "r"
+)
+
+// RecordingHeader is the header of an asciicast (asciinema v2) recording
+type RecordingHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+// RecordingEvent is an event in an asciicast recording
+type RecordingEvent struct {
+	// Time is the number of seconds since the start of the recording
+	Time float64
+	Type string
+	Data string
+}
+
+// MarshalJSON encodes the event as an array of time, type and data
+func (e RecordingEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes the event from an array of time, type and data
+func (e *RecordingEvent) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("event has %v fields (expected 3)", len(raw))
+	}
+
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return fmt.Errorf("invalid event time: %w", err)
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return fmt.Errorf("invalid event type: %w", err)
+	}
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return fmt.Errorf("invalid event data: %w", err)
+	}
+
+	return nil
+}
+
+// Recorder writes terminal output and resizes to an asciicast file. It implements io.Writer (recording output) so it
+// can be used with io.TeeReader / io.MultiWriter
+type Recorder struct {
+	mutex   sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	start   time.Time
+	partial []byte
+	err     error
+	closed  bool
+}
+
+// NewRecorder creates an asciicast file and writes its header
+func NewRecorder(file string, size ConsoleSize, title string, env map[string]string) (*Recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r := &Recorder{
+		file:  f,
+		w:     bufio.NewWriter(f),
+		start: time.Now(),
+	}
+	if err := r.writeLine(RecordingHeader{
+		Version:   2,
+		Width:     size.Width,
+		Height:    size.Height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env:       env,
+	}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// event records an event (r.mutex must be held)
+func (r *Recorder) event(t, data string) {
+	if r.err != nil || r.closed {
+		return
+	}
+
+	// asciinema uses microsecond precision
+	elapsed := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	r.err = r.writeLine(RecordingEvent{Time: elapsed, Type: t, Data: data})
+}
+
+// Write records terminal output. Errors are only returned by Close so a failed recording doesn't interrupt the
+// session being recorded
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Output can be split in the middle of a UTF-8 sequence, hold the incomplete part back until the rest arrives
+	data := append(r.partial, p...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+
+	r.partial = append([]byte(nil), data[end:]...)
+	if end > 0 {
+		r.event(RecordingEventOutput, string(data[:end]))
+	}
+
+	return len(p), nil
+}
+
+// Resize records a change in terminal size
+func (r *Recorder) Resize(s ConsoleSize) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.event(RecordingEventResize, fmt.Sprintf("%vx%v", s.Width, s.Height))
+}
+
+// Close flushes any remaining output and closes the recording, returning the first error encountered while recording
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.partial) != 0 {
+		r.event(RecordingEventOutput, string(r.partial))
+		r.partial = nil
+	}
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := r.file.Close(); r.err == nil {
+		r.err = err
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("failed to write recording: %w", r.err)
+	}
+	return nil
+}
+
+// RecordingReader reads events from an asciicast recording
+type RecordingReader struct {
+	Header RecordingHeader
+
+	decoder *json.Decoder
+}
+
+// NewRecordingReader reads the header of an asciicast recording
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	d := json.NewDecoder(r)
+
+	var h RecordingHeader
+	if err := d.Decode(&h); err != nil {
+		return nil, fmt.Errorf("failed to parse recording header: %w", err)
+	}
+	if h.Version != 2 {
+		return nil, fmt.Errorf("unsupported recording version %v (only asciicast v2 is supported)", h.Version)
+	}
+
+	return &RecordingReader{
+		Header:  h,
+		decoder: d,
+	}, nil
+}
+
+// Next reads the next event in the recording, returning io.EOF at the end
+func (r *RecordingReader) Next() (RecordingEvent, error) {
+	var e RecordingEvent
+	if err := r.decoder.Decode(&e); err != nil {
+		if errors.Is(err, io.EOF) {
+			return e, io.EOF
+		}
+
+		return e, fmt.Errorf("failed to parse recording event: %w", err)
+	}
+
+	return e, nil
+}
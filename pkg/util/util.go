@@ -3,30 +3,26 @@ package util
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"sort"
 	"strings"
 	"syscall"
-	"text/template"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/dgrijalva/jwt-go/v4"
 	"github.com/google/go-github/v32/github"
 	"github.com/jedib0t/go-pretty/v6/progress"
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/mattn/go-isatty"
+	"github.com/netsoc/cli/pkg/printer"
 	"github.com/netsoc/cli/version"
 	iam "github.com/netsoc/iam/client"
 	webspaced "github.com/netsoc/webspaced/client"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh/terminal"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -44,11 +40,30 @@ const (
 	DateOnlyFormat = "2006-01-02"
 	// UpdateRepo is the repository to check for updates on
 	UpdateRepo = "netsoc/cli"
+	// AnnotationProfileUser marks a flag which should default to the selected profile's user (see AddOptProfileUser)
+	AnnotationProfileUser = "netsoc_profile_user"
 )
 
 // ExitCode is the code the process should exit with (without an error)
 var ExitCode int
 
+// ExitCodeTimeout is the exit code for commands which time out (the same as timeout(1))
+const ExitCodeTimeout = 124
+
+// ExitCodeError is an error which should make the process exit with a specific code (instead of 1)
+type ExitCodeError struct {
+	Err  error
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
+
 // Debugf prints log messages only if debugging is enabled
 func Debugf(format string, v ...interface{}) {
 	if !IsDebug {
@@ -58,22 +73,32 @@ func Debugf(format string, v ...interface{}) {
 	log.Printf(format, v...)
 }
 
+// apiError creates an error from a message, indicating if the API responded with 401 Unauthorized (the generated
+// error string is the HTTP status)
+func apiError(status, message string) error {
+	if strings.HasPrefix(status, "401") {
+		return &UnauthorizedError{Message: message}
+	}
+
+	return errors.New(message)
+}
+
 // APIError re-formats an OpenAPI-generated API client error
 func APIError(err error) error {
 	var iamGeneric iam.GenericOpenAPIError
 	if ok := errors.As(err, &iamGeneric); ok {
 		if iamError, ok := iamGeneric.Model().(iam.Error); ok {
-			return errors.New(iamError.Message)
+			return apiError(iamGeneric.Error(), iamError.Message)
 		}
-		return err
+		return apiError(iamGeneric.Error(), err.Error())
 	}
 
 	var wsdGeneric webspaced.GenericOpenAPIError
 	if ok := errors.As(err, &wsdGeneric); ok {
 		if wsdError, ok := wsdGeneric.Model().(webspaced.Error); ok {
-			return errors.New(wsdError.Message)
+			return apiError(wsdGeneric.Error(), wsdError.Message)
 		}
-		return err
+		return apiError(wsdGeneric.Error(), err.Error())
 	}
 
 	return err
@@ -98,20 +123,25 @@ func ReadPassword(confirm bool) (string, error) {
 		return string(p[:n]), nil
 	}
 
-	fmt.Print("Enter password: ")
+	return PromptSecret("Enter password", confirm)
+}
+
+// PromptSecret prompts for a secret on the terminal (without echoing it)
+func PromptSecret(prompt string, confirm bool) (string, error) {
+	fmt.Fprintf(os.Stderr, "%v: ", prompt)
 	p, err := terminal.ReadPassword(int(os.Stdin.Fd()))
 	if err != nil {
 		return "", fmt.Errorf("read failed: %w", err)
 	}
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 
 	if confirm {
-		fmt.Print("Again: ")
+		fmt.Fprint(os.Stderr, "Again: ")
 		p2, err := terminal.ReadPassword(int(os.Stdin.Fd()))
 		if err != nil {
 			return "", fmt.Errorf("read failed: %w", err)
 		}
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 
 		if string(p2) != string(p) {
 			return "", ErrPasswordMismatch
@@ -157,118 +187,38 @@ type UserClaims struct {
 	Version uint `json:"version"`
 }
 
-type usersList []iam.User
-
-func (l usersList) Len() int {
-	return len(l)
-}
-func (l usersList) Less(i, j int) bool {
-	return l[i].Id < l[j].Id
-}
-func (l usersList) Swap(i, j int) {
-	l[i], l[j] = l[j], l[i]
+// AddOptUser adds the user option to a command
+func AddOptUser(cmd *cobra.Command, p *string) {
+	cmd.Flags().StringVarP(p, "user", "u", "self", "(admin only) user to perform action as")
 }
 
-// PrintUsers renders a list of users (with various output options)
-func PrintUsers(users []iam.User, outputType string, single bool) error {
-	sUsers := usersList(users)
-	sort.Sort(sUsers)
-
-	var data interface{}
-	data = sUsers
-	if single && len(users) == 1 {
-		data = users[0]
-	}
-
-	if strings.HasPrefix(outputType, "template=") {
-		tpl, err := template.New("anonymous").Parse(strings.TrimPrefix(outputType, "template="))
-		if err != nil {
-			return fmt.Errorf("failed to parse template: %w", err)
-		}
-
-		if err := tpl.Execute(os.Stdout, data); err != nil {
-			return fmt.Errorf("failed to execute template: %w", err)
-		}
-
-		return nil
-	}
-
-	switch outputType {
-	case "json":
-		if err := json.NewEncoder(os.Stdout).Encode(data); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
-		}
-	case "yaml":
-		if err := yaml.NewEncoder(os.Stdout).Encode(data); err != nil {
-			return fmt.Errorf("failed to encode YAML: %w", err)
-		}
-	case "table-wide", "wide":
-		t := table.NewWriter()
-		t.AppendHeader(table.Row{"ID", "Username", "Admin", "Email", "Verified", "Name", "Renewed", "Created / Updated"})
-		t.SetStyle(table.StyleRounded)
-		t.Style().Options.SeparateRows = true
-
-		for _, u := range users {
-			admin := "no"
-			if u.IsAdmin != nil && *u.IsAdmin {
-				admin = "yes"
-			}
-
-			verified := "no"
-			if u.Verified != nil && *u.Verified {
-				verified = "yes"
-			}
-
-			renewed := u.Renewed.Local().Format(TableDateFormat)
-			if u.Renewed.Before(time.Unix(0, 0)) {
-				renewed = "never"
-			}
-
-			createdUpdated := u.Meta.Created.Local().Format(TableDateFormat) + "\n" + u.Meta.Updated.Local().Format(TableDateFormat)
-
-			t.AppendRow(table.Row{
-				fmt.Sprint(u.Id),
-				u.Username,
-				admin,
-				u.Email,
-				verified,
-				u.FirstName + " " + u.LastName,
-				renewed,
-				createdUpdated,
-			})
-		}
-
-		fmt.Println(t.Render())
-	case "table":
-		t := table.NewWriter()
-		t.AppendHeader(table.Row{"ID", "Username", "Email", "Name", "Renewed"})
-		t.SetStyle(table.StyleRounded)
-
-		for _, u := range users {
-			renewed := u.Renewed.Local().Format(TableDateFormat)
-			if u.Renewed.Before(time.Unix(0, 0)) {
-				renewed = "never"
-			}
-
-			t.AppendRow(table.Row{fmt.Sprint(u.Id), u.Username, u.Email, u.FirstName + " " + u.LastName, renewed})
-		}
-
-		fmt.Println(t.Render())
-	default:
-		return fmt.Errorf(`unknown output format "%v"`, outputType)
-	}
+// AddOptProfileUser adds the user option to a command, defaulting to the selected profile's default user (if set)
+// instead of self. Only commands which just read from (or open a session with) a webspace should use this, so nothing
+// is changed or deleted for a user that wasn't asked for explicitly.
+func AddOptProfileUser(cmd *cobra.Command, p *string) {
+	cmd.Flags().StringVarP(p, "user", "u", "self", "(admin only) user to perform action as (defaults to the "+
+		"profile's default user)")
+	cmd.Flags().SetAnnotation("user", AnnotationProfileUser, []string{"true"})
+}
 
-	return nil
+// AddOptProfileUsers adds a user option that can be passed multiple times, defaulting to the selected profile's
+// default user like AddOptProfileUser
+func AddOptProfileUsers(cmd *cobra.Command, p *[]string) {
+	cmd.Flags().StringSliceVarP(p, "user", "u", []string{"self"}, "(admin only) users to perform action as "+
+		"(can be repeated, defaults to the profile's default user)")
+	cmd.Flags().SetAnnotation("user", AnnotationProfileUser, []string{"true"})
 }
 
-// AddOptUser adds the user option to a command
-func AddOptUser(cmd *cobra.Command, p *string) {
-	cmd.Flags().StringVarP(p, "user", "u", "self", "(admin only) user to perform action as")
+// AddOptFormat adds the output format options to a command
+func AddOptFormat(cmd *cobra.Command, o *printer.Options) {
+	printer.AddFlags(cmd, o, "table")
 }
 
-// AddOptFormat adds the output format option to a command
-func AddOptFormat(cmd *cobra.Command, p *string) {
-	cmd.Flags().StringVarP(p, "output", "o", "table", "output format `table|wide|yaml|json|template=<Go template>`")
+// AddOptWatch adds the --watch and --interval options
+func AddOptWatch(cmd *cobra.Command, w *printer.WatchOptions) {
+	cmd.Flags().BoolVarP(&w.Enabled, "watch", "w", false, "keep watching for changes (redraw if interactive, "+
+		"otherwise print changed objects as ndjson)")
+	cmd.Flags().DurationVar(&w.Interval, "interval", 2*time.Second, "`interval` between updates when watching")
 }
 
 // CheckUpdate checks to see if a new version is available
@@ -297,7 +247,8 @@ func CheckUpdate() (string, error) {
 	return "", nil
 }
 
-// SimpleProgress renders a simple progress
+// SimpleProgress renders a simple progress, which is indeterminate (only showing that something is happening) if eta is
+// 0
 func SimpleProgress(message string, eta time.Duration) (func(), progress.Writer, *progress.Tracker) {
 	if !IsInteractive() {
 		return func() {}, progress.NewWriter(), &progress.Tracker{}
@@ -314,11 +265,13 @@ func SimpleProgress(message string, eta time.Duration) (func(), progress.Writer,
 
 	t := &progress.Tracker{
 		Message: message,
-		Total:   1,
 		Units:   progress.UnitsDefault,
 
 		ExpectedDuration: eta,
 	}
+	if eta != 0 {
+		t.Total = 1
+	}
 	w.AppendTracker(t)
 
 	return func() {
@@ -326,6 +279,41 @@ func SimpleProgress(message string, eta time.Duration) (func(), progress.Writer,
 	}, w, t
 }
 
+// TransferProgress shows a progress bar for transferring total bytes (use Tracker.Increment or a ProgressWriter to
+// report progress, and Tracker.MarkAsDone when finished). The returned function waits for rendering to finish.
+func TransferProgress(message string, total int64) (func(), *progress.Tracker) {
+	t := &progress.Tracker{
+		Message: message,
+		Total:   total,
+		Units:   progress.UnitsBytes,
+	}
+	if !IsInteractive() {
+		return func() {}, t
+	}
+
+	w := progress.NewWriter()
+	w.SetAutoStop(true)
+	w.ShowETA(true)
+	w.SetTrackerPosition(progress.PositionRight)
+	w.SetUpdateFrequency(100 * time.Millisecond)
+	go w.Render()
+	w.AppendTracker(t)
+
+	return func() {
+		time.Sleep(250 * time.Millisecond)
+	}, t
+}
+
+// ProgressWriter reports the number of bytes written to it to a progress tracker
+type ProgressWriter struct {
+	Tracker *progress.Tracker
+}
+
+func (w ProgressWriter) Write(p []byte) (int, error) {
+	w.Tracker.Increment(int64(len(p)))
+	return len(p), nil
+}
+
 // EscapeReader transparently reads an escape sequence (^]) from an io.Reader, assumes a TTY in raw mode (one byte at
 // a time reads)
 type EscapeReader struct {
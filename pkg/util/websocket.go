@@ -19,7 +19,7 @@ type wsError struct {
 }
 
 // WebspacedWebsocket opens a websocket to webspaced
-func WebspacedWebsocket(c *config.Config, user, endpoint string) (*websocket.Conn, error) {
+func WebspacedWebsocket(c *config.Config, token, user, endpoint string) (*websocket.Conn, error) {
 	url, err := url.Parse(c.URLs.Webspaced)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse webspaced URL: %w", err)
@@ -35,7 +35,7 @@ func WebspacedWebsocket(c *config.Config, user, endpoint string) (*websocket.Con
 	url.Path = url.Path + "/webspace/" + user + "/" + endpoint
 
 	headers := make(http.Header)
-	headers.Add("Authorization", "Bearer "+c.Token)
+	headers.Add("Authorization", "Bearer "+token)
 	conn, res, err := websocket.DefaultDialer.Dial(url.String(), headers)
 	if errors.Is(err, websocket.ErrBadHandshake) {
 		var e wsError
@@ -43,6 +43,9 @@ func WebspacedWebsocket(c *config.Config, user, endpoint string) (*websocket.Con
 			return nil, err
 		}
 
+		if res.StatusCode == http.StatusUnauthorized {
+			return nil, &UnauthorizedError{Message: e.Message}
+		}
 		return nil, errors.New(e.Message)
 	}
 